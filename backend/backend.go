@@ -0,0 +1,76 @@
+// Package backend defines the storage of the Servers and implements the
+// Vaults, the Archives and the chunks on top of any of them.
+package backend
+
+import (
+	"context"
+	"errors"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+var (
+	// ErrNotFound is returned when a chunk or a key does not exist.
+	ErrNotFound = errors.New("backend: not found")
+
+	// ErrReadOnly is returned when a Tx of Backend.View is modified.
+	ErrReadOnly = errors.New("backend: read-only transaction")
+
+	// ErrClosed is returned after Backend.Close.
+	ErrClosed = errors.New("backend: closed")
+)
+
+// Tx is a transaction of the metadata, a set of keys and values. The values
+// must not be modified and they are only valid until the transaction ends.
+type Tx interface {
+
+	// Get returns the value of the key, or ErrNotFound.
+	Get(key string) ([]byte, error)
+
+	// Put sets the value of the key.
+	Put(key string, value []byte) error
+
+	// Delete removes the key, or returns ErrNotFound.
+	Delete(key string) error
+
+	// Scan calls fn with each key with the prefix and its value in ascending
+	// order of the keys, until fn returns an error, which is returned. The
+	// keys must not be modified meanwhile.
+	Scan(prefix string, fn func(key string, value []byte) error) error
+}
+
+// Backend stores the chunks and the metadata of a Server. All its methods may
+// be called concurrently.
+type Backend interface {
+
+	// PutChunk stores p, an encoded chunk.Record, with the id unless the id
+	// is already stored. It returns whether p was stored. p is durable when it
+	// returns.
+	PutChunk(ctx context.Context, id chunk.ID, p []byte) (bool, error)
+
+	// GetChunk returns the bytes stored with the id, or ErrNotFound.
+	GetChunk(ctx context.Context, id chunk.ID) ([]byte, error)
+
+	// HasChunks returns whether each of the ids is stored.
+	HasChunks(ctx context.Context, ids []chunk.ID) ([]bool, error)
+
+	// DeleteChunk removes the chunk with the id, or returns ErrNotFound.
+	DeleteChunk(ctx context.Context, id chunk.ID) error
+
+	// Chunks calls fn with the id of each chunk stored in ascending order of
+	// their binary form, until fn returns an error, which is returned. Chunks
+	// stored or deleted meanwhile may be skipped.
+	Chunks(ctx context.Context, fn func(id chunk.ID) error) error
+
+	// View calls fn with a read-only Tx that sees a consistent state.
+	View(ctx context.Context, fn func(Tx) error) error
+
+	// Update calls fn with a read-write Tx. The Updates are serialized. If fn
+	// returns nil, all changes are committed at once and are durable when it
+	// returns, or else none of them is, and the error is returned.
+	Update(ctx context.Context, fn func(Tx) error) error
+
+	// Close releases the resources. The other methods return ErrClosed
+	// afterwards.
+	Close() error
+}
@@ -0,0 +1,646 @@
+// Package backendtest is the conformance test suite of the implementations of
+// backend.Backend, which every backend runs from its own tests.
+package backendtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+	"github.com/daniel-fanjul-alcuten/floc/vault/vaulttest"
+)
+
+// Open opens the Backend stored in dir, which is empty the first time.
+type Open func(t *testing.T, dir string) backend.Backend
+
+// ErrCrash is returned by the Updates interrupted by a Crash.
+var ErrCrash = errors.New("backendtest: crash")
+
+// Crash makes the next Update of b, a Backend returned by Open, stop before
+// its step n, counting from 0, as if the process crashed there: it returns an
+// error that wraps ErrCrash and leaves the stored data as it is. An Update with fewer steps
+// completes normally. The steps are the writes that make the Update durable.
+type Crash func(b backend.Backend, n int)
+
+// Test runs the suite against the Backends returned by open. If crash is not
+// nil, the Backends are persistent, and it also checks that the committed
+// data survives closing and opening again the same dir, and that an Update
+// interrupted at any step is found all or none when the dir is opened again.
+func Test(t *testing.T, open Open, crash Crash) {
+	newBackend := func(t *testing.T) backend.Backend {
+		b := open(t, t.TempDir())
+		t.Cleanup(func() {
+			b.Close()
+		})
+		return b
+	}
+	for _, c := range []struct {
+		name string
+		f    func(*testing.T, backend.Backend)
+	}{
+		{"Chunks", testChunks},
+		{"ChunksConcurrent", testChunksConcurrent},
+		{"Tx", testTx},
+		{"TxRollback", testTxRollback},
+		{"TxConcurrent", testTxConcurrent},
+		{"Collect", testCollect},
+		{"Append", testAppend},
+		{"Methods", testMethods},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.f(t, newBackend(t))
+		})
+	}
+	t.Run("Closed", func(t *testing.T) {
+		testClosed(t, open(t, t.TempDir()))
+	})
+	if crash != nil {
+		t.Run("Persistence", func(t *testing.T) {
+			testPersistence(t, open)
+		})
+		t.Run("Crash", func(t *testing.T) {
+			testCrash(t, open, crash)
+		})
+	}
+	t.Run("Service", func(t *testing.T) {
+		vaulttest.Test(t, func(t *testing.T) vault.Service {
+			return &backend.Service{Backend: newBackend(t)}
+		})
+	})
+}
+
+// ID returns a valid chunk.ID that depends on i.
+func ID(i int) chunk.ID {
+	id := chunk.ID{Algorithm: chunk.SHA256}
+	id.Sum[0], id.Sum[1], id.Sum[31] = byte(i>>8), byte(i), 0xff
+	return id
+}
+
+// Record returns an encoded chunk.Record of data and its ID.
+func Record(t *testing.T, data string) (chunk.ID, []byte) {
+	r, err := chunk.NewRecord(chunk.Hasher{Algorithm: chunk.SHA256}, buffers.Buffers{}.Append([]byte(data)), chunk.None)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return r.ID, buf.Bytes()
+}
+
+func ids(t *testing.T, b backend.Backend) (s []chunk.ID) {
+	if err := b.Chunks(context.Background(), func(id chunk.ID) error {
+		s = append(s, id)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func testChunks(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	if s := ids(t, b); len(s) != 0 {
+		t.Error(s)
+	}
+	order := []int{5, 300, 1, 4, 2}
+	for _, i := range order {
+		if stored, err := b.PutChunk(ctx, ID(i), []byte(fmt.Sprint("chunk ", i))); err != nil || !stored {
+			t.Fatal(i, stored, err)
+		}
+	}
+	if stored, err := b.PutChunk(ctx, ID(5), []byte("other")); err != nil || stored {
+		t.Error(stored, err)
+	}
+	if p, err := b.GetChunk(ctx, ID(5)); err != nil || string(p) != "chunk 5" {
+		t.Error(string(p), err)
+	}
+	if _, err := b.GetChunk(ctx, ID(3)); err != backend.ErrNotFound {
+		t.Error(err)
+	}
+	has, err := b.HasChunks(ctx, []chunk.ID{ID(1), ID(3), ID(300), ID(3), ID(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(has); s != "[true false true false true]" {
+		t.Error(s)
+	}
+	want := []chunk.ID{ID(1), ID(2), ID(4), ID(5), ID(300)}
+	if s := ids(t, b); fmt.Sprint(s) != fmt.Sprint(want) {
+		t.Error(s)
+	}
+	other := chunk.ID{Algorithm: chunk.SHA512_256}
+	if _, err := b.PutChunk(ctx, other, []byte("x")); err != nil {
+		t.Error(err)
+	}
+	want = append(want, other)
+	if s := ids(t, b); fmt.Sprint(s) != fmt.Sprint(want) {
+		t.Error(s)
+	}
+	if err := b.DeleteChunk(ctx, ID(4)); err != nil {
+		t.Error(err)
+	}
+	if err := b.DeleteChunk(ctx, ID(4)); err != backend.ErrNotFound {
+		t.Error(err)
+	}
+	if has, err := b.HasChunks(ctx, []chunk.ID{ID(4)}); err != nil || has[0] {
+		t.Error(has, err)
+	}
+	stop := errors.New("stop")
+	n := 0
+	if err := b.Chunks(ctx, func(chunk.ID) error {
+		n++
+		return stop
+	}); err != stop || n != 1 {
+		t.Error(err, n)
+	}
+}
+
+func testChunksConcurrent(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	stored := make(map[int]int)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				ok, err := b.PutChunk(ctx, ID(i), []byte(fmt.Sprint(i)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if ok {
+					mu.Lock()
+					stored[i]++
+					mu.Unlock()
+				}
+				if p, err := b.GetChunk(ctx, ID(i)); err != nil || string(p) != fmt.Sprint(i) {
+					t.Error(g, i, string(p), err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 0; i < 50; i++ {
+		if stored[i] != 1 {
+			t.Error(i, stored[i])
+		}
+	}
+}
+
+func scan(t *testing.T, tx backend.Tx, prefix string) string {
+	var s []string
+	if err := tx.Scan(prefix, func(key string, value []byte) error {
+		s = append(s, key+"="+string(value))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprint(s)
+}
+
+func testTx(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		for _, k := range []string{"b/2", "a/1", "b/1", "b", "c", "b/10"} {
+			if err := tx.Put(k, []byte(k)); err != nil {
+				return err
+			}
+		}
+		if p, err := tx.Get("b/1"); err != nil || string(p) != "b/1" {
+			t.Error(string(p), err)
+		}
+		if s := scan(t, tx, "b/"); s != "[b/1=b/1 b/10=b/10 b/2=b/2]" {
+			t.Error(s)
+		}
+		return tx.Put("c", []byte("c2"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		if s := scan(t, tx, ""); s != "[a/1=a/1 b=b b/1=b/1 b/10=b/10 b/2=b/2 c=c2]" {
+			t.Error(s)
+		}
+		if _, err := tx.Get("d"); err != backend.ErrNotFound {
+			t.Error(err)
+		}
+		if err := tx.Put("d", nil); err != backend.ErrReadOnly {
+			t.Error(err)
+		}
+		if err := tx.Delete("c"); err != backend.ErrReadOnly {
+			t.Error(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		if err := tx.Delete("b"); err != nil {
+			return err
+		}
+		if err := tx.Delete("b"); err != backend.ErrNotFound {
+			t.Error(err)
+		}
+		if _, err := tx.Get("b"); err != backend.ErrNotFound {
+			t.Error(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		if s := scan(t, tx, "b"); s != "[b/1=b/1 b/10=b/10 b/2=b/2]" {
+			t.Error(s)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testTxRollback(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		return tx.Put("a", []byte("1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fail := errors.New("fail")
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		if err := tx.Put("a", []byte("2")); err != nil {
+			return err
+		}
+		if err := tx.Put("b", []byte("2")); err != nil {
+			return err
+		}
+		return fail
+	}); err != fail {
+		t.Error(err)
+	}
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		if s := scan(t, tx, ""); s != "[a=1]" {
+			t.Error(s)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testTxConcurrent(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := b.Update(ctx, func(tx backend.Tx) error {
+					n := 0
+					if p, err := tx.Get("n"); err == nil {
+						fmt.Sscan(string(p), &n)
+					} else if err != backend.ErrNotFound {
+						return err
+					}
+					return tx.Put("n", []byte(fmt.Sprint(n+1)))
+				}); err != nil {
+					t.Error(err)
+				}
+				if err := b.View(ctx, func(tx backend.Tx) error {
+					_, err := tx.Get("n")
+					return err
+				}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		if p, err := tx.Get("n"); err != nil || string(p) != "160" {
+			t.Error(string(p), err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testClosed(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.PutChunk(ctx, ID(1), nil); err != backend.ErrClosed {
+		t.Error(err)
+	}
+	if _, err := b.GetChunk(ctx, ID(1)); err != backend.ErrClosed {
+		t.Error(err)
+	}
+	if _, err := b.HasChunks(ctx, []chunk.ID{ID(1)}); err != backend.ErrClosed {
+		t.Error(err)
+	}
+	if err := b.DeleteChunk(ctx, ID(1)); err != backend.ErrClosed {
+		t.Error(err)
+	}
+	if err := b.Chunks(ctx, func(chunk.ID) error { return nil }); err != backend.ErrClosed {
+		t.Error(err)
+	}
+	if err := b.View(ctx, func(backend.Tx) error { return nil }); err != backend.ErrClosed {
+		t.Error(err)
+	}
+	if err := b.Update(ctx, func(backend.Tx) error { return nil }); err != backend.ErrClosed {
+		t.Error(err)
+	}
+}
+
+func testPersistence(t *testing.T, open Open) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	b := open(t, dir)
+	if _, err := b.PutChunk(ctx, ID(1), []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.PutChunk(ctx, ID(2), []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeleteChunk(ctx, ID(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		return tx.Put("a", []byte("1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		tx.Put("b", []byte("2"))
+		return errors.New("fail")
+	}); err == nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b = open(t, dir)
+	defer b.Close()
+	if s := ids(t, b); fmt.Sprint(s) != fmt.Sprint([]chunk.ID{ID(1)}) {
+		t.Error(s)
+	}
+	if p, err := b.GetChunk(ctx, ID(1)); err != nil || string(p) != "one" {
+		t.Error(string(p), err)
+	}
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		if s := scan(t, tx, ""); s != "[a=1]" {
+			t.Error(s)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func state(t *testing.T, b backend.Backend) (s string) {
+	if err := b.View(context.Background(), func(tx backend.Tx) error {
+		s = scan(t, tx, "")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func testCrash(t *testing.T, open Open, crash Crash) {
+	ctx := context.Background()
+	const before, after = "[a=1 b=1]", "[a=2 c/d=3]"
+	for n := 0; ; n++ {
+		dir := t.TempDir()
+		b := open(t, dir)
+		if err := b.Update(ctx, func(tx backend.Tx) error {
+			tx.Put("a", []byte("1"))
+			return tx.Put("b", []byte("1"))
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Close(); err != nil {
+			t.Fatal(err)
+		}
+		b = open(t, dir)
+		crash(b, n)
+		err := b.Update(ctx, func(tx backend.Tx) error {
+			tx.Put("a", []byte("2"))
+			tx.Delete("b")
+			return tx.Put("c/d", []byte("3"))
+		})
+		if err == nil {
+			if s := state(t, b); s != after {
+				t.Error(n, s)
+			}
+			b.Close()
+			if n == 0 {
+				t.Error("no steps")
+			}
+			return
+		}
+		if !errors.Is(err, ErrCrash) {
+			t.Fatal(n, err)
+		}
+		// b is abandoned without Close, like the crashed process.
+		b = open(t, dir)
+		if s := state(t, b); s != before && s != after {
+			t.Error(n, s)
+		}
+		if err := b.Update(ctx, func(tx backend.Tx) error {
+			return tx.Put("e", []byte("4"))
+		}); err != nil {
+			t.Error(n, err)
+		}
+		if err := b.Close(); err != nil {
+			t.Error(n, err)
+		}
+		if n == 100 {
+			t.Fatal("too many steps")
+		}
+	}
+}
+
+func testCollect(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	s := &backend.Service{Backend: b, Grace: time.Second}
+	if _, err := s.CreateVault(ctx, vaulttest.Metadata(t, "v")); err != nil {
+		t.Fatal(err)
+	}
+	id1, p1 := Record(t, "one")
+	id2, p2 := Record(t, "two")
+	for _, p := range [][]byte{p1, p2} {
+		if _, err := s.PutChunk(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := s.BeginArchive(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendArchive(ctx, "v", a.Time, []vault.Entry{{Path: "f", Chunks: []vault.ChunkRef{{ID: id1, Length: 3}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Collect(ctx); err != backend.ErrBusy {
+		t.Error(err)
+	}
+	if _, err := s.CommitArchive(ctx, "v", a.Time); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Collect(ctx); err != nil || n != 0 {
+		t.Error(n, err)
+	}
+	time.Sleep(s.Grace + 100*time.Millisecond)
+	if has, err := s.HasChunks(ctx, []chunk.ID{id2}); err != nil || !has[0] {
+		t.Error(has, err)
+	}
+	if err := s.DeleteVault(ctx, "v"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Collect(ctx); err != nil || n != 1 {
+		t.Error(n, err)
+	}
+	if s := fmt.Sprint(ids(t, b)); s != fmt.Sprint([]chunk.ID{id2}) {
+		t.Error(s)
+	}
+	if n, err := (&backend.Service{Backend: b}).Collect(ctx); err != nil || n != 0 {
+		t.Error(n, err)
+	}
+	if s := fmt.Sprint(ids(t, b)); s != fmt.Sprint([]chunk.ID{id2}) {
+		t.Error(s)
+	}
+}
+
+func testAppend(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	s := &backend.Service{Backend: b}
+	if _, err := s.CreateVault(ctx, vaulttest.Metadata(t, "v")); err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.BeginArchive(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendPath := func(path string) {
+		if err := s.AppendArchive(ctx, "v", a.Time, []vault.Entry{{Path: path}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() (s string) {
+		if err := b.View(ctx, func(tx backend.Tx) error {
+			s = scan(t, tx, "count/")
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if c := count(); !strings.HasSuffix(c, "=0]") {
+		t.Error(c)
+	}
+	appendPath("a")
+	appendPath("b")
+	if c := count(); !strings.HasSuffix(c, "=2]") {
+		t.Error(c)
+	}
+	appendPath("c")
+	appendPath("d")
+	if c := count(); !strings.HasSuffix(c, "=4]") {
+		t.Error(c)
+	}
+	if _, err := s.CommitArchive(ctx, "v", a.Time); err != nil {
+		t.Fatal(err)
+	}
+	if c := count(); c != "[]" {
+		t.Error(c)
+	}
+	a, err = s.GetArchive(ctx, "v", a.Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range a.Entries {
+		paths = append(paths, e.Path)
+	}
+	if p := fmt.Sprint(paths); p != "[a b c d]" {
+		t.Error(p)
+	}
+}
+
+func testMethods(t *testing.T, b backend.Backend) {
+	ctx := context.Background()
+	s := &backend.Service{Backend: b}
+	c := &backend.Client{Client: vault.Client{Conn: vaulttest.Serve(t, s.Methods())}}
+	id, p := Record(t, "data")
+	if stored, err := c.PutChunk(ctx, p); err != nil || !stored {
+		t.Error(stored, err)
+	}
+	if stored, err := c.PutChunk(ctx, p); err != nil || stored {
+		t.Error(stored, err)
+	}
+	if q, err := c.GetChunk(ctx, id); err != nil || !bytes.Equal(p, q) {
+		t.Error(q, err)
+	}
+	if has, err := c.HasChunks(ctx, []chunk.ID{ID(1), id}); err != nil || fmt.Sprint(has) != "[false true]" {
+		t.Error(has, err)
+	}
+	if _, err := c.GetChunk(ctx, ID(1)); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	q := append([]byte(nil), p...)
+	q[len(q)-1]++
+	if _, err := c.PutChunk(ctx, q); !errors.Is(err, vault.ErrInvalid) {
+		t.Error(err)
+	}
+	if _, err := c.PutChunk(ctx, append(p, 0)); !errors.Is(err, vault.ErrInvalid) {
+		t.Error(err)
+	}
+	r, err := chunk.NewRecord(chunk.Hasher{Algorithm: chunk.SHA256}, buffers.Buffers{}.Append([]byte("data")), chunk.None)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ID = ID(1)
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PutChunk(ctx, buf.Bytes()); !errors.Is(err, vault.ErrInvalid) {
+		t.Error(err)
+	}
+	if has, err := c.HasChunks(ctx, []chunk.ID{ID(1)}); err != nil || has[0] {
+		t.Error(has, err)
+	}
+	if _, err := c.Client.CreateVault(ctx, vaulttest.Metadata(t, "v")); err != nil {
+		t.Error(err)
+	}
+	if m, err := s.Vault(ctx, "v"); err != nil || m.Name != "v" {
+		t.Error(m, err)
+	}
+	a, err := c.BeginArchive(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Collect(ctx); err != backend.ErrBusy {
+		t.Error(err)
+	}
+	if _, err := c.AbortArchive(ctx, "v", a.Time); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Collect(ctx); err != nil || n != 0 {
+		t.Error(n, err)
+	}
+}
@@ -0,0 +1,91 @@
+package backendtest
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/client"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+	"github.com/daniel-fanjul-alcuten/floc/vault/vaulttest"
+)
+
+// Server tests a command that serves a Backend at the network and address
+// with run until ctx is done. It stores a Vault and a chunk through a client,
+// restarts the command and reads them back.
+func Server(t *testing.T, network, address string, run func(ctx context.Context) error) {
+	ctx := context.Background()
+	id, p := Record(t, "data")
+	t.Run("Put", func(t *testing.T) {
+		start(t, run)
+		dial(t, network, address, func(c *backend.Client) error {
+			if _, err := c.CreateVault(ctx, vaulttest.Metadata(t, "v")); err != nil {
+				return err
+			}
+			stored, err := c.PutChunk(ctx, p)
+			if !stored {
+				t.Error(stored)
+			}
+			return err
+		})
+	})
+	t.Run("Get", func(t *testing.T) {
+		start(t, run)
+		dial(t, network, address, func(c *backend.Client) error {
+			if v, err := c.GetVault(ctx, "v"); err != nil || v.Name != "v" {
+				t.Error(v, err)
+			}
+			q, err := c.GetChunk(ctx, id)
+			if string(q) != string(p) {
+				t.Error(q)
+			}
+			return err
+		})
+	})
+}
+
+// start calls run until the end of the test.
+func start(t *testing.T, run func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// dial calls session with a backend.Client of the server, retrying while it
+// does not listen yet. The other errors, like those of the handshake, are
+// fatal.
+func dial(t *testing.T, network, address string, session func(*backend.Client) error) {
+	c := &client.Client{
+		Network:   network,
+		Address:   address,
+		Timeout:   time.Second,
+		SessionID: "test",
+		Session: func(conn *jrpc.Conn) error {
+			return session(&backend.Client{Client: vault.Client{Conn: conn}})
+		},
+	}
+	for i := 0; ; i++ {
+		err := c.Dial()
+		if err == nil {
+			return
+		}
+		// A unix socket does not exist until the server listens.
+		refused := errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT)
+		if !refused || i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,314 @@
+// Package fs implements a backend.Backend in a directory tree of plain files,
+// which can be inspected with standard tools and copied with rsync.
+//
+// The chunks are the files objects/ALGORITHM/AB/CDEF… where ABCDEF… is the
+// hexadecimal sum of their ids. The metadata are the files meta/KEY= where the
+// segments of KEY between the slashes are escaped and the suffix '=' allows a
+// key to be a prefix of other keys. Every file is written to tmp, synced and
+// renamed or linked into place, and its directory is synced. The changes of an
+// Update are first written to the file journal, which is replayed by Open if
+// they were not applied completely.
+package fs
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+// ErrBroken is returned by View and Update after an Update committed its
+// journal but could not apply it, until the Backend is opened again, which
+// applies it.
+var ErrBroken = errors.New("fs: journal not applied, open the backend again")
+
+// The names of the entries of the root directory.
+const (
+	objectsDir  = "objects"
+	metaDir     = "meta"
+	tmpDir      = "tmp"
+	journalFile = "journal"
+)
+
+// Backend stores the chunks and the metadata in the directory Dir. It must be
+// created by Open.
+type Backend struct {
+	Dir string
+
+	// mu is held for writing to apply the Updates and to Close.
+	mu     sync.RWMutex
+	update sync.Mutex
+	closed bool
+
+	// broken is the error of an Update that committed its journal but could
+	// not apply it.
+	broken error
+
+	// failpoint, if it is not nil, is called by the Updates before each step
+	// that writes or removes files, and its error stops the Update there as
+	// if the process crashed. It is set by the tests.
+	failpoint func() error
+}
+
+// step calls b.failpoint, if any.
+func (b *Backend) step() error {
+	if b.failpoint == nil {
+		return nil
+	}
+	return b.failpoint()
+}
+
+// Open creates the directory tree in dir if it does not exist, applies the
+// journal of an interrupted Update and removes the temporary files.
+func Open(dir string) (*Backend, error) {
+	for _, d := range []string{objectsDir, metaDir, tmpDir} {
+		if err := mkdirAll(filepath.Join(dir, d)); err != nil {
+			return nil, err
+		}
+	}
+	b := &Backend{Dir: dir}
+	if err := b.recover(); err != nil {
+		return nil, err
+	}
+	tmps, err := os.ReadDir(filepath.Join(dir, tmpDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range tmps {
+		if err := os.Remove(filepath.Join(dir, tmpDir, e.Name())); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// syncDir syncs the directory name, so that the entries created, renamed or
+// removed in it are durable.
+func syncDir(name string) error {
+	d, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// mkdirAll creates the directory name and its missing parents and syncs their
+// parents.
+func mkdirAll(name string) error {
+	if _, err := os.Stat(name); err == nil {
+		return nil
+	}
+	parent := filepath.Dir(name)
+	if parent != name {
+		if err := mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(name, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return syncDir(parent)
+}
+
+// writeTemp writes p to a new synced file in tmp and returns its name.
+func (b *Backend) writeTemp(p []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Join(b.Dir, tmpDir), "")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(p); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// objectPath returns the name of the file of the chunk with the id.
+func (b *Backend) objectPath(id chunk.ID) string {
+	s := hex.EncodeToString(id.Sum[:])
+	return filepath.Join(b.Dir, objectsDir, id.Algorithm.String(), s[:2], s[2:])
+}
+
+// PutChunk implements backend.Backend. The file is linked into place, so that
+// only one of concurrent calls with the same id stores it.
+func (b *Backend) PutChunk(ctx context.Context, id chunk.ID, p []byte) (bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return false, backend.ErrClosed
+	}
+	if !id.Algorithm.Valid() {
+		return false, chunk.ErrAlgorithm
+	}
+	name := b.objectPath(id)
+	if _, err := os.Lstat(name); err == nil {
+		return false, nil
+	}
+	tmp, err := b.writeTemp(p)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+	if err := mkdirAll(filepath.Dir(name)); err != nil {
+		return false, err
+	}
+	if err := os.Link(tmp, name); err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := syncDir(filepath.Dir(name)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetChunk implements backend.Backend.
+func (b *Backend) GetChunk(ctx context.Context, id chunk.ID) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return nil, backend.ErrClosed
+	}
+	p, err := os.ReadFile(b.objectPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, backend.ErrNotFound
+	}
+	return p, err
+}
+
+// HasChunks implements backend.Backend.
+func (b *Backend) HasChunks(ctx context.Context, ids []chunk.ID) ([]bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return nil, backend.ErrClosed
+	}
+	has := make([]bool, len(ids))
+	for i, id := range ids {
+		_, err := os.Lstat(b.objectPath(id))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		has[i] = err == nil
+	}
+	return has, nil
+}
+
+// DeleteChunk implements backend.Backend.
+func (b *Backend) DeleteChunk(ctx context.Context, id chunk.ID) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	name := b.objectPath(id)
+	if err := os.Remove(name); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return backend.ErrNotFound
+		}
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// readDirNames returns the sorted names in the directory name, or none if it
+// does not exist.
+func readDirNames(name string) ([]string, error) {
+	entries, err := os.ReadDir(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names, nil
+}
+
+// Chunks implements backend.Backend. The files whose names are not ids are
+// skipped.
+func (b *Backend) Chunks(ctx context.Context, fn func(chunk.ID) error) error {
+	b.mu.RLock()
+	closed := b.closed
+	b.mu.RUnlock()
+	if closed {
+		return backend.ErrClosed
+	}
+	root := filepath.Join(b.Dir, objectsDir)
+	names, err := readDirNames(root)
+	if err != nil {
+		return err
+	}
+	var algorithms []chunk.Algorithm
+	for _, n := range names {
+		if a, err := chunk.ParseAlgorithm(n); err == nil {
+			algorithms = append(algorithms, a)
+		}
+	}
+	sort.Slice(algorithms, func(i, j int) bool { return algorithms[i] < algorithms[j] })
+	for _, a := range algorithms {
+		shards, err := readDirNames(filepath.Join(root, a.String()))
+		if err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			files, err := readDirNames(filepath.Join(root, a.String(), shard))
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				s := shard + f
+				id := chunk.ID{Algorithm: a}
+				if len(s) != 2*chunk.Size || s != strings.ToLower(s) {
+					continue
+				}
+				if _, err := hex.Decode(id.Sum[:], []byte(s)); err != nil {
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(id); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements backend.Backend.
+func (b *Backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,232 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/backend/backendtest"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+func TestBackend(t *testing.T) {
+	backendtest.Test(t, func(t *testing.T, dir string) backend.Backend {
+		b, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}, func(b backend.Backend, n int) {
+		b.(*Backend).failpoint = func() error {
+			if n == 0 {
+				return backendtest.ErrCrash
+			}
+			n--
+			return nil
+		}
+	})
+}
+
+func TestEscape(t *testing.T) {
+	for i, c := range []struct{ s, e string }{
+		{"vault", "vault"}, // 0
+		{"20240102T020405.000000006Z", "20240102T020405.000000006Z"}, // 1
+		{"", "%"},                // 2
+		{".", "%2E"},             // 3
+		{"..", "%2E%2E"},         // 4
+		{"...", "..."},           // 5
+		{"a b=%", "a%20b%3D%25"}, // 6
+		{"\x00\xff", "%00%FF"},   // 7
+	} {
+		if e := escape(c.s); e != c.e {
+			t.Error(i, e)
+		}
+		if s, err := unescape(c.e); err != nil || s != c.s {
+			t.Error(i, s, err)
+		}
+	}
+	for i, e := range []string{"a=", "%2", "%2e", "%41", "a%", "a/b", "."} {
+		if s, err := unescape(e); err != errName {
+			t.Error(i, s, err)
+		}
+	}
+}
+
+func TestBackend_Layout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	b, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	id := backendtest.ID(0x1234)
+	if _, err := b.PutChunk(ctx, id, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := os.ReadFile(filepath.Join(dir, "objects", "sha256", "12", fmt.Sprintf("34%060x", 0xff))); err != nil || string(p) != "data" {
+		t.Error(string(p), err)
+	}
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		tx.Put("vault/my vault", []byte("1"))
+		tx.Put("vault", []byte("2"))
+		return tx.Put("../x", []byte("3"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{
+		"meta/vault/my%20vault=": "1",
+		"meta/vault=":            "2",
+		"meta/%2E%2E/x=":         "3",
+	} {
+		if p, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(p) != value {
+			t.Error(name, string(p), err)
+		}
+	}
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		return tx.Delete("vault/my vault")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "meta", "vault")); !os.IsNotExist(err) {
+		t.Error(err)
+	}
+	for _, name := range []string{"objects/sha256/12/xyz", "objects/sha256/12/AB", "objects/md5/00/00", "meta/vault/x", "meta/vault/a=b="} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids []chunk.ID
+	if err := b.Chunks(ctx, func(id chunk.ID) error {
+		ids = append(ids, id)
+		return nil
+	}); err != nil || len(ids) != 1 || ids[0] != id {
+		t.Error(ids, err)
+	}
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		return tx.Scan("", func(key string, value []byte) error {
+			if key != "../x" && key != "vault" {
+				t.Error(key)
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpen_Journal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	b, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		tx.Put("a", []byte("1"))
+		return tx.Put("b", []byte("1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	// An Update interrupted after the commit of its journal.
+	p, err := json.Marshal([]change{{Key: "a", Delete: true}, {Key: "b", Value: []byte("2")}, {Key: "c/d", Value: []byte{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "journal"), p, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tmp", "partial"), p, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		var s []string
+		err := tx.Scan("", func(key string, value []byte) error {
+			s = append(s, key+"="+string(value))
+			return nil
+		})
+		if fmt.Sprint(s) != "[b=2 c/d=]" {
+			t.Error(s)
+		}
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"journal", "tmp/partial"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Error(name, err)
+		}
+	}
+}
+
+func TestUpdate_Broken(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	b, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := errors.New("fail")
+	// The first step of the first apply fails.
+	n := 0
+	b.failpoint = func() error {
+		if n++; n == 3 {
+			return fail
+		}
+		return nil
+	}
+	if err := b.Update(ctx, func(tx backend.Tx) error {
+		return tx.Put("a", []byte("1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Every apply fails.
+	n = 0
+	b.failpoint = func() error {
+		if n++; n > 2 {
+			return fail
+		}
+		return nil
+	}
+	update := func() error {
+		return b.Update(ctx, func(tx backend.Tx) error {
+			return tx.Put("a", []byte("2"))
+		})
+	}
+	if err := update(); !errors.Is(err, ErrBroken) || !errors.Is(err, fail) {
+		t.Error(err)
+	}
+	b.failpoint = nil
+	if err := update(); !errors.Is(err, ErrBroken) {
+		t.Error(err)
+	}
+	if err := b.View(ctx, func(backend.Tx) error { return nil }); !errors.Is(err, ErrBroken) {
+		t.Error(err)
+	}
+	b.Close()
+	if b, err = Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.View(ctx, func(tx backend.Tx) error {
+		if p, err := tx.Get("a"); err != nil || string(p) != "2" {
+			t.Error(string(p), err)
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,404 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+)
+
+// valueSuffix ends the names of the files of the keys. It is never found in
+// the escaped segments, which are the names of the directories.
+const valueSuffix = "="
+
+func safe(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+const upperhex = "0123456789ABCDEF"
+
+// escape returns a file name for the segment s of a key. The bytes other than
+// letters, digits, '-', '_' and '.' are escaped as %XX, like the dots of the
+// segments "." and "..", and the empty segment is "%".
+func escape(s string) string {
+	if s == "" {
+		return "%"
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if safe(c) && !(c == '.' && (s == "." || s == "..")) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(upperhex[c>>4])
+			b.WriteByte(upperhex[c&15])
+		}
+	}
+	return b.String()
+}
+
+// errName is returned by unescape for the files that are not keys.
+var errName = errors.New("fs: invalid file name")
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// unescape is the inverse of escape.
+func unescape(s string) (string, error) {
+	if s == "%" {
+		return "", nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '%' {
+			if !safe(c) {
+				return "", errName
+			}
+			b.WriteByte(c)
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", errName
+		}
+		h, ok1 := unhex(s[i+1])
+		l, ok2 := unhex(s[i+2])
+		if !ok1 || !ok2 {
+			return "", errName
+		}
+		b.WriteByte(h<<4 | l)
+		i += 2
+	}
+	if escape(b.String()) != s {
+		return "", errName
+	}
+	return b.String(), nil
+}
+
+// keyPath returns the name of the file of the key.
+func (b *Backend) keyPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = escape(s)
+	}
+	segments[len(segments)-1] += valueSuffix
+	return filepath.Join(b.Dir, metaDir, filepath.Join(segments...))
+}
+
+// pathKey is the inverse of keyPath for the names relative to meta.
+func pathKey(rel string) (string, error) {
+	if !strings.HasSuffix(rel, valueSuffix) {
+		return "", errName
+	}
+	segments := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, valueSuffix)), "/")
+	for i, s := range segments {
+		var err error
+		if segments[i], err = unescape(s); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// scan calls fn with the keys with the prefix that are stored in files, in
+// ascending order.
+func (b *Backend) scan(prefix string, fn func(key string) error) error {
+	dir := filepath.Join(b.Dir, metaDir)
+	if i := strings.LastIndexByte(prefix, '/'); i >= 0 {
+		segments := strings.Split(prefix[:i], "/")
+		for i, s := range segments {
+			segments[i] = escape(s)
+		}
+		dir = filepath.Join(dir, filepath.Join(segments...))
+	}
+	var keys []string
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && name == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.Join(b.Dir, metaDir), name)
+		if err != nil {
+			return err
+		}
+		if key, err := pathKey(rel); err == nil && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tx reads the files and, unless it is read-only, keeps its changes in
+// writes, where nil values are deletions.
+type tx struct {
+	b        *Backend
+	writes   map[string][]byte
+	readOnly bool
+}
+
+func (t *tx) Get(key string) ([]byte, error) {
+	if p, ok := t.writes[key]; ok {
+		if p == nil {
+			return nil, backend.ErrNotFound
+		}
+		return p, nil
+	}
+	p, err := os.ReadFile(t.b.keyPath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, backend.ErrNotFound
+	}
+	return p, err
+}
+
+func (t *tx) Put(key string, value []byte) error {
+	if t.readOnly {
+		return backend.ErrReadOnly
+	}
+	t.writes[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (t *tx) Delete(key string) error {
+	if t.readOnly {
+		return backend.ErrReadOnly
+	}
+	if _, err := t.Get(key); err != nil {
+		return err
+	}
+	t.writes[key] = nil
+	return nil
+}
+
+func (t *tx) Scan(prefix string, fn func(string, []byte) error) error {
+	var keys []string
+	if err := t.b.scan(prefix, func(k string) error {
+		if _, ok := t.writes[k]; !ok {
+			keys = append(keys, k)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for k, v := range t.writes {
+		if v != nil && strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p, err := t.Get(k)
+		if err != nil {
+			return err
+		}
+		if err := fn(k, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// View implements backend.Backend. The Updates wait for it to return before
+// they apply their changes.
+func (b *Backend) View(ctx context.Context, fn func(backend.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	if b.broken != nil {
+		return fmt.Errorf("%w: %w", ErrBroken, b.broken)
+	}
+	return fn(&tx{b: b, readOnly: true})
+}
+
+// change is an entry of the journal.
+type change struct {
+	Key    string `json:"key"`
+	Value  []byte `json:"value,omitempty"`
+	Delete bool   `json:"delete,omitempty"`
+}
+
+// Update implements backend.Backend. The changes are committed when the
+// journal is renamed into place, and then they are applied to the files, twice
+// if needed. If they cannot be applied, it returns an error that wraps
+// ErrBroken, and they are applied by Open.
+func (b *Backend) Update(ctx context.Context, fn func(backend.Tx) error) error {
+	b.update.Lock()
+	defer b.update.Unlock()
+	b.mu.RLock()
+	closed, broken := b.closed, b.broken
+	b.mu.RUnlock()
+	if closed {
+		return backend.ErrClosed
+	}
+	if broken != nil {
+		return fmt.Errorf("%w: %w", ErrBroken, broken)
+	}
+	// Only the Updates modify the files, so they can be read without b.mu.
+	t := &tx{b: b, writes: make(map[string][]byte)}
+	if err := fn(t); err != nil {
+		return err
+	}
+	if len(t.writes) == 0 {
+		return nil
+	}
+	changes := make([]change, 0, len(t.writes))
+	for k, v := range t.writes {
+		changes = append(changes, change{Key: k, Value: v, Delete: v == nil})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	p, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	if err := b.step(); err != nil {
+		return err
+	}
+	tmp, err := b.writeTemp(p)
+	if err != nil {
+		return err
+	}
+	if err := b.step(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(b.Dir, journalFile)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := syncDir(b.Dir); err != nil {
+		b.broken = err
+		return fmt.Errorf("%w: %w", ErrBroken, err)
+	}
+	if err := b.apply(changes); err != nil {
+		// The journal is committed, so it is applied again like Open does.
+		if err := b.apply(changes); err != nil {
+			b.broken = err
+			return fmt.Errorf("%w: %w", ErrBroken, err)
+		}
+	}
+	return nil
+}
+
+// recover applies the journal, if any.
+func (b *Backend) recover() error {
+	p, err := os.ReadFile(filepath.Join(b.Dir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var changes []change
+	if err := json.Unmarshal(p, &changes); err != nil {
+		return err
+	}
+	return b.apply(changes)
+}
+
+// apply writes and removes the files of the changes, which may have been
+// applied partially before, and then removes the journal.
+func (b *Backend) apply(changes []change) error {
+	dirs := make(map[string]bool)
+	for _, c := range changes {
+		if err := b.step(); err != nil {
+			return err
+		}
+		name := b.keyPath(c.Key)
+		dir := filepath.Dir(name)
+		if c.Delete {
+			if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			dirs[dir] = true
+			continue
+		}
+		if err := mkdirAll(dir); err != nil {
+			return err
+		}
+		tmp, err := b.writeTemp(c.Value)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		dirs[dir] = true
+	}
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	if err := b.step(); err != nil {
+		return err
+	}
+	if err := b.prune(dirs); err != nil {
+		return err
+	}
+	if err := b.step(); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(b.Dir, journalFile)); err != nil {
+		return err
+	}
+	return syncDir(b.Dir)
+}
+
+// prune removes the empty directories of the keys and their empty parents
+// below meta.
+func (b *Backend) prune(dirs map[string]bool) error {
+	root := filepath.Join(b.Dir, metaDir)
+	for dir := range dirs {
+		for ; dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			names, err := readDirNames(dir)
+			if err != nil {
+				return err
+			}
+			if len(names) > 0 {
+				break
+			}
+			if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if err := syncDir(filepath.Dir(dir)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
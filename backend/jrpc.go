@@ -0,0 +1,101 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+// The names of the JSON RPC methods of the chunks and their params. The
+// chunk.Records are encoded in base64 strings.
+const (
+	// PutMethod params: record.
+	PutMethod = "Chunk.Put"
+
+	// GetMethod params: id.
+	GetMethod = "Chunk.Get"
+
+	// HasMethod params: []id.
+	HasMethod = "Chunk.Has"
+
+	// CollectMethod has no params.
+	CollectMethod = "Chunk.Collect"
+)
+
+func decode(params []interface{}, ps ...interface{}) error {
+	if err := jrpc.DecodeParams(params, ps...); err != nil {
+		return fmt.Errorf("%w: %v", vault.ErrInvalid, err)
+	}
+	return nil
+}
+
+// Methods returns the JSON RPC methods of the Vaults, the Archives and the
+// chunks of s, to be added to server.Server.Methods.
+func (s *Service) Methods() map[string]jrpc.Method {
+	m := vault.Methods(s)
+	m[PutMethod] = func(ctx context.Context, params []interface{}) (interface{}, error) {
+		var p []byte
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.PutChunk(ctx, p)
+	}
+	m[GetMethod] = func(ctx context.Context, params []interface{}) (interface{}, error) {
+		var id chunk.ID
+		if err := decode(params, &id); err != nil {
+			return nil, err
+		}
+		return s.GetChunk(ctx, id)
+	}
+	m[HasMethod] = func(ctx context.Context, params []interface{}) (interface{}, error) {
+		var ids []chunk.ID
+		if err := decode(params, &ids); err != nil {
+			return nil, err
+		}
+		return s.HasChunks(ctx, ids)
+	}
+	m[CollectMethod] = func(ctx context.Context, params []interface{}) (interface{}, error) {
+		if err := decode(params); err != nil {
+			return nil, err
+		}
+		return s.Collect(ctx)
+	}
+	return m
+}
+
+// Client calls the methods of Service.Methods through Conn.
+type Client struct {
+	vault.Client
+}
+
+// PutChunk calls Service.PutChunk.
+func (c *Client) PutChunk(ctx context.Context, p []byte) (stored bool, err error) {
+	err = c.Call(ctx, &stored, PutMethod, p)
+	return
+}
+
+// GetChunk calls Service.GetChunk.
+func (c *Client) GetChunk(ctx context.Context, id chunk.ID) (p []byte, err error) {
+	err = c.Call(ctx, &p, GetMethod, id)
+	return
+}
+
+// HasChunks calls Service.HasChunks.
+func (c *Client) HasChunks(ctx context.Context, ids []chunk.ID) (has []bool, err error) {
+	err = c.Call(ctx, &has, HasMethod, ids)
+	return
+}
+
+// Collect calls Service.Collect. It returns ErrBusy like the Service.
+func (c *Client) Collect(ctx context.Context) (n int, err error) {
+	err = c.Call(ctx, &n, CollectMethod)
+	var e *jrpc.Error
+	if errors.As(err, &e) && e.Error() == ErrBusy.Error() {
+		err = ErrBusy
+	}
+	return
+}
@@ -0,0 +1,302 @@
+// Package mem implements a backend.Backend in memory, which may be saved to and
+// loaded from a snapshot file.
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+// Backend keeps the chunks and the metadata in maps. The zero value is an
+// empty Backend ready to use.
+type Backend struct {
+	mu     sync.RWMutex
+	update sync.Mutex
+	closed bool
+	chunks map[chunk.ID][]byte
+	keys   map[string][]byte
+}
+
+func (b *Backend) init() {
+	if b.chunks == nil {
+		b.chunks = make(map[chunk.ID][]byte)
+		b.keys = make(map[string][]byte)
+	}
+}
+
+// PutChunk implements backend.Backend.
+func (b *Backend) PutChunk(ctx context.Context, id chunk.ID, p []byte) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false, backend.ErrClosed
+	}
+	b.init()
+	if _, ok := b.chunks[id]; ok {
+		return false, nil
+	}
+	b.chunks[id] = append([]byte(nil), p...)
+	return true, nil
+}
+
+// GetChunk implements backend.Backend.
+func (b *Backend) GetChunk(ctx context.Context, id chunk.ID) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return nil, backend.ErrClosed
+	}
+	p, ok := b.chunks[id]
+	if !ok {
+		return nil, backend.ErrNotFound
+	}
+	return p, nil
+}
+
+// HasChunks implements backend.Backend.
+func (b *Backend) HasChunks(ctx context.Context, ids []chunk.ID) ([]bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return nil, backend.ErrClosed
+	}
+	has := make([]bool, len(ids))
+	for i, id := range ids {
+		_, has[i] = b.chunks[id]
+	}
+	return has, nil
+}
+
+// DeleteChunk implements backend.Backend.
+func (b *Backend) DeleteChunk(ctx context.Context, id chunk.ID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	if _, ok := b.chunks[id]; !ok {
+		return backend.ErrNotFound
+	}
+	delete(b.chunks, id)
+	return nil
+}
+
+// Chunks implements backend.Backend. It calls fn with the ids stored when it
+// is called.
+func (b *Backend) Chunks(ctx context.Context, fn func(chunk.ID) error) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return backend.ErrClosed
+	}
+	ids := make([]chunk.ID, 0, len(b.chunks))
+	for id := range b.chunks {
+		ids = append(ids, id)
+	}
+	b.mu.RUnlock()
+	backend.SortIDs(ids)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tx reads keys and, unless it is read-only, keeps its changes in writes,
+// where nil values are deletions.
+type tx struct {
+	keys     map[string][]byte
+	writes   map[string][]byte
+	readOnly bool
+}
+
+func (t *tx) Get(key string) ([]byte, error) {
+	p, ok := t.writes[key]
+	if !ok {
+		p, ok = t.keys[key]
+	}
+	if !ok || p == nil {
+		return nil, backend.ErrNotFound
+	}
+	return p, nil
+}
+
+func (t *tx) Put(key string, value []byte) error {
+	if t.readOnly {
+		return backend.ErrReadOnly
+	}
+	t.writes[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (t *tx) Delete(key string) error {
+	if t.readOnly {
+		return backend.ErrReadOnly
+	}
+	if _, err := t.Get(key); err != nil {
+		return err
+	}
+	t.writes[key] = nil
+	return nil
+}
+
+func (t *tx) Scan(prefix string, fn func(string, []byte) error) error {
+	var keys []string
+	for k := range t.keys {
+		if _, ok := t.writes[k]; !ok && strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	for k, v := range t.writes {
+		if v != nil && strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p, _ := t.Get(k)
+		if err := fn(k, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// View implements backend.Backend. The Updates wait for it to return.
+func (b *Backend) View(ctx context.Context, fn func(backend.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	return fn(&tx{keys: b.keys, readOnly: true})
+}
+
+// Update implements backend.Backend. The Views are not blocked until the
+// changes are applied.
+func (b *Backend) Update(ctx context.Context, fn func(backend.Tx) error) error {
+	b.update.Lock()
+	defer b.update.Unlock()
+	b.mu.Lock()
+	closed := b.closed
+	b.init()
+	b.mu.Unlock()
+	if closed {
+		return backend.ErrClosed
+	}
+	// Only the Updates modify b.keys, so they can be read without b.mu.
+	t := &tx{keys: b.keys, writes: make(map[string][]byte)}
+	if err := fn(t); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	for k, v := range t.writes {
+		if v == nil {
+			delete(b.keys, k)
+		} else {
+			b.keys[k] = v
+		}
+	}
+	return nil
+}
+
+// Close implements backend.Backend. The data is kept for Save.
+func (b *Backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	b.closed = true
+	return nil
+}
+
+// snapshot is the JSON document of a Backend in a file.
+type snapshot struct {
+	Chunks []snapshotChunk   `json:"chunks"`
+	Keys   map[string][]byte `json:"keys"`
+}
+
+type snapshotChunk struct {
+	ID   chunk.ID `json:"id"`
+	Data []byte   `json:"data"`
+}
+
+// Save writes all the data of b to the file name, replacing it atomically. It
+// may be called after Close.
+func (b *Backend) Save(name string) error {
+	b.update.Lock()
+	defer b.update.Unlock()
+	b.mu.RLock()
+	ids := make([]chunk.ID, 0, len(b.chunks))
+	for id := range b.chunks {
+		ids = append(ids, id)
+	}
+	backend.SortIDs(ids)
+	s := snapshot{Chunks: make([]snapshotChunk, len(ids)), Keys: b.keys}
+	for i, id := range ids {
+		s.Chunks[i] = snapshotChunk{id, b.chunks[id]}
+	}
+	b.mu.RUnlock()
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := json.NewEncoder(f).Encode(&s); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// Load replaces the data of b with the file name written by Save.
+func (b *Backend) Load(name string) error {
+	p, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var s snapshot
+	if err := json.Unmarshal(p, &s); err != nil {
+		return err
+	}
+	b.update.Lock()
+	defer b.update.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return backend.ErrClosed
+	}
+	b.chunks = make(map[chunk.ID][]byte, len(s.Chunks))
+	for _, c := range s.Chunks {
+		b.chunks[c.ID] = c.Data
+	}
+	b.keys = s.Keys
+	if b.keys == nil {
+		b.keys = make(map[string][]byte)
+	}
+	return nil
+}
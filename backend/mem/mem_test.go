@@ -0,0 +1,77 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/backend/backendtest"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+// saving saves the Backend to the file name after each Update and when it is
+// closed.
+type saving struct {
+	*Backend
+	name string
+
+	// crash, if it is not negative, is the step of the next Update where it
+	// stops: 0 before the Update and 1 before the Save.
+	crash int
+}
+
+func (s *saving) Update(ctx context.Context, fn func(backend.Tx) error) error {
+	if s.crash == 0 {
+		return backendtest.ErrCrash
+	}
+	if err := s.Backend.Update(ctx, fn); err != nil {
+		return err
+	}
+	if s.crash == 1 {
+		return backendtest.ErrCrash
+	}
+	return s.Save(s.name)
+}
+
+func (s *saving) Close() error {
+	if err := s.Backend.Close(); err != nil {
+		return err
+	}
+	return s.Save(s.name)
+}
+
+func TestBackend(t *testing.T) {
+	backendtest.Test(t, func(t *testing.T, dir string) backend.Backend {
+		s := &saving{&Backend{}, filepath.Join(dir, "snapshot"), -1}
+		if err := s.Load(s.name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+		return s
+	}, func(b backend.Backend, n int) {
+		b.(*saving).crash = n
+	})
+}
+
+func TestBackend_Load(t *testing.T) {
+	ctx := context.Background()
+	name := filepath.Join(t.TempDir(), "snapshot")
+	if err := (&Backend{}).Save(name); err != nil {
+		t.Fatal(err)
+	}
+	b := &Backend{}
+	if _, err := b.PutChunk(ctx, backendtest.ID(1), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Load(name); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := b.HasChunks(ctx, []chunk.ID{backendtest.ID(1)}); err != nil || has[0] {
+		t.Error(has, err)
+	}
+	if err := b.Load(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,516 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+	"github.com/daniel-fanjul-alcuten/floc/stats"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+// ErrBusy is returned by Service.Collect while there are Open Archives.
+var ErrBusy = errors.New("backend: archives are open")
+
+// DefaultGrace is the Grace of a Service that has none.
+const DefaultGrace = time.Hour
+
+// The prefixes of the keys of the metadata.
+const (
+	vaultPrefix   = "vault/"
+	archivePrefix = "archive/"
+	entryPrefix   = "entry/"
+
+	// countPrefix is the prefix of the number of appends of the Open
+	// Archives.
+	countPrefix = "count/"
+)
+
+// timeKey formats t in UTC with a fixed width, so that the keys sort like the
+// times.
+func timeKey(t time.Time) string {
+	return t.UTC().Format("20060102T150405.000000000Z")
+}
+
+func archiveKey(name string, t time.Time) string {
+	return archivePrefix + name + "/" + timeKey(t)
+}
+
+func countKey(name string, t time.Time) string {
+	return countPrefix + name + "/" + timeKey(t)
+}
+
+// Service implements vault.Service and the chunk methods on top of Backend.
+type Service struct {
+	Backend Backend
+
+	// Stats, if it is not nil, counts the chunks stored and deduplicated and
+	// the bytes stored.
+	Stats *stats.Stats
+
+	// Grace is how long Collect spares the chunks stored or found by
+	// PutChunk and HasChunks, which the Archives of the clients may not
+	// reference yet. Zero means DefaultGrace.
+	Grace time.Duration
+
+	// gc excludes Collect from the methods that may add references.
+	gc sync.RWMutex
+
+	pins pins
+}
+
+// pins keeps the last time that each chunk was stored or found, since start.
+type pins struct {
+	sync.Mutex
+	start time.Time
+	ids   map[chunk.ID]time.Time
+
+	// next is the number of ids that prunes them again.
+	next int
+}
+
+// init starts p at now unless it started already.
+func (p *pins) init(now time.Time) {
+	if p.ids == nil {
+		p.start, p.ids = now, make(map[chunk.ID]time.Time)
+	}
+}
+
+// prune forgets the ids pinned before t.
+func (p *pins) prune(t time.Time) {
+	for id, u := range p.ids {
+		if u.Before(t) {
+			delete(p.ids, id)
+		}
+	}
+}
+
+func (s *Service) grace() time.Duration {
+	if s.Grace > 0 {
+		return s.Grace
+	}
+	return DefaultGrace
+}
+
+// pin records that the ids are stored or found now. s.gc must be held for
+// reading.
+func (s *Service) pin(ids ...chunk.ID) {
+	now := time.Now()
+	p := &s.pins
+	p.Lock()
+	defer p.Unlock()
+	p.init(now)
+	for _, id := range ids {
+		p.ids[id] = now
+	}
+	if len(p.ids) >= p.next {
+		p.prune(now.Add(-s.grace()))
+		p.next = 2*len(p.ids) + 1024
+	}
+}
+
+func notFound(err error) error {
+	if err == ErrNotFound {
+		return vault.ErrNotFound
+	}
+	return err
+}
+
+func get(tx Tx, key string, v interface{}) error {
+	p, err := tx.Get(key)
+	if err != nil {
+		return notFound(err)
+	}
+	return json.Unmarshal(p, v)
+}
+
+func put(tx Tx, key string, v interface{}) error {
+	p, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Put(key, p)
+}
+
+// CreateVault implements vault.Service.
+func (s *Service) CreateVault(ctx context.Context, m vault.Metadata) (v vault.Vault, err error) {
+	if err = m.Validate(); err != nil {
+		return
+	}
+	if m.Keyring != nil && m.Keyring.Vault != m.Name {
+		return v, fmt.Errorf("%w: keyring of vault %q", vault.ErrInvalid, m.Keyring.Vault)
+	}
+	v = vault.Vault{Metadata: m, Created: time.Now().UTC()}
+	err = s.Backend.Update(ctx, func(tx Tx) error {
+		if _, err := tx.Get(vaultPrefix + m.Name); err == nil {
+			return vault.ErrExists
+		} else if err != ErrNotFound {
+			return err
+		}
+		return put(tx, vaultPrefix+m.Name, v)
+	})
+	return
+}
+
+// ListVaults implements vault.Service.
+func (s *Service) ListVaults(ctx context.Context) (vs []vault.Vault, err error) {
+	vs = []vault.Vault{}
+	err = s.Backend.View(ctx, func(tx Tx) error {
+		return tx.Scan(vaultPrefix, func(key string, p []byte) error {
+			var v vault.Vault
+			if err := json.Unmarshal(p, &v); err != nil {
+				return err
+			}
+			vs = append(vs, v)
+			return nil
+		})
+	})
+	return
+}
+
+// GetVault implements vault.Service.
+func (s *Service) GetVault(ctx context.Context, name string) (v vault.Vault, err error) {
+	err = s.Backend.View(ctx, func(tx Tx) error {
+		return get(tx, vaultPrefix+name, &v)
+	})
+	return
+}
+
+// SetKeyring implements vault.Service.
+func (s *Service) SetKeyring(ctx context.Context, name string, k *crypt.Keyring) (v vault.Vault, err error) {
+	if k != nil && k.Vault != name {
+		return v, fmt.Errorf("%w: keyring of vault %q", vault.ErrInvalid, k.Vault)
+	}
+	err = s.Backend.Update(ctx, func(tx Tx) error {
+		if err := get(tx, vaultPrefix+name, &v); err != nil {
+			return err
+		}
+		v.Keyring = k
+		return put(tx, vaultPrefix+name, v)
+	})
+	return
+}
+
+// Vault returns the Metadata of the Vault with the name, for
+// server.Server.Vault.
+func (s *Service) Vault(ctx context.Context, name string) (vault.Metadata, error) {
+	v, err := s.GetVault(ctx, name)
+	return v.Metadata, err
+}
+
+// deleteAll deletes all keys with the prefix.
+func deleteAll(tx Tx, prefix string) error {
+	var keys []string
+	if err := tx.Scan(prefix, func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := tx.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteVault implements vault.Service. The chunks are only deleted by
+// Collect.
+func (s *Service) DeleteVault(ctx context.Context, name string) error {
+	return s.Backend.Update(ctx, func(tx Tx) error {
+		if err := tx.Delete(vaultPrefix + name); err != nil {
+			return notFound(err)
+		}
+		for _, prefix := range []string{archivePrefix, entryPrefix, countPrefix} {
+			if err := deleteAll(tx, prefix+name+"/"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BeginArchive implements vault.Service.
+func (s *Service) BeginArchive(ctx context.Context, name string) (a vault.Archive, err error) {
+	s.gc.RLock()
+	defer s.gc.RUnlock()
+	err = s.Backend.Update(ctx, func(tx Tx) error {
+		if _, err := tx.Get(vaultPrefix + name); err != nil {
+			return notFound(err)
+		}
+		last := ""
+		if err := tx.Scan(archivePrefix+name+"/", func(key string, _ []byte) error {
+			last = key
+			return nil
+		}); err != nil {
+			return err
+		}
+		a = vault.Archive{Vault: name, Time: time.Now().UTC(), State: vault.Open}
+		for archiveKey(name, a.Time) <= last {
+			a.Time = a.Time.Add(time.Nanosecond)
+		}
+		if err := put(tx, countKey(name, a.Time), 0); err != nil {
+			return err
+		}
+		return put(tx, archiveKey(name, a.Time), a)
+	})
+	return
+}
+
+func getArchive(tx Tx, name string, t time.Time) (a vault.Archive, err error) {
+	if _, err = tx.Get(vaultPrefix + name); err != nil {
+		return a, notFound(err)
+	}
+	err = get(tx, archiveKey(name, t), &a)
+	return
+}
+
+// AppendArchive implements vault.Service. The number of appends is kept in a
+// key of the Archive from BeginArchive until it is finished, so that they are
+// not counted again.
+func (s *Service) AppendArchive(ctx context.Context, name string, t time.Time, entries []vault.Entry) error {
+	return s.Backend.Update(ctx, func(tx Tx) error {
+		a, err := getArchive(tx, name, t)
+		if err != nil {
+			return err
+		}
+		if a.State != vault.Open {
+			return vault.ErrState
+		}
+		var n int
+		if err := get(tx, countKey(name, t), &n); err != nil {
+			return err
+		}
+		if err := put(tx, countKey(name, t), n+1); err != nil {
+			return err
+		}
+		return put(tx, fmt.Sprintf("%s%s/%s/%010d", entryPrefix, name, timeKey(t), n), entries)
+	})
+}
+
+func (s *Service) finish(ctx context.Context, name string, t time.Time, state vault.State) (a vault.Archive, err error) {
+	err = s.Backend.Update(ctx, func(tx Tx) error {
+		if a, err = getArchive(tx, name, t); err != nil {
+			return err
+		}
+		if a.State != vault.Open {
+			return vault.ErrState
+		}
+		a.State, a.Finished = state, time.Now().UTC()
+		if err := tx.Delete(countKey(name, t)); err != nil {
+			return err
+		}
+		return put(tx, archiveKey(name, t), a)
+	})
+	return
+}
+
+// CommitArchive implements vault.Service.
+func (s *Service) CommitArchive(ctx context.Context, name string, t time.Time) (vault.Archive, error) {
+	return s.finish(ctx, name, t, vault.Complete)
+}
+
+// AbortArchive implements vault.Service.
+func (s *Service) AbortArchive(ctx context.Context, name string, t time.Time) (vault.Archive, error) {
+	return s.finish(ctx, name, t, vault.Incomplete)
+}
+
+// ListArchives implements vault.Service.
+func (s *Service) ListArchives(ctx context.Context, name string) (as []vault.Archive, err error) {
+	as = []vault.Archive{}
+	err = s.Backend.View(ctx, func(tx Tx) error {
+		if _, err := tx.Get(vaultPrefix + name); err != nil {
+			return notFound(err)
+		}
+		return tx.Scan(archivePrefix+name+"/", func(key string, p []byte) error {
+			var a vault.Archive
+			if err := json.Unmarshal(p, &a); err != nil {
+				return err
+			}
+			as = append(as, a)
+			return nil
+		})
+	})
+	return
+}
+
+// GetArchive implements vault.Service.
+func (s *Service) GetArchive(ctx context.Context, name string, t time.Time) (a vault.Archive, err error) {
+	err = s.Backend.View(ctx, func(tx Tx) error {
+		if a, err = getArchive(tx, name, t); err != nil {
+			return err
+		}
+		return tx.Scan(entryPrefix+name+"/"+timeKey(t)+"/", func(key string, p []byte) error {
+			var entries []vault.Entry
+			if err := json.Unmarshal(p, &entries); err != nil {
+				return err
+			}
+			a.Entries = append(a.Entries, entries...)
+			return nil
+		})
+	})
+	return
+}
+
+// verify checks the ID of r against its data if r is a whole plain Record
+// with an unkeyed ID, which is the only kind that a Server can check.
+func verify(r *chunk.Record) error {
+	if r.ID.Algorithm.Keyed() || r.Cipher != chunk.Plain || r.Parity.Data > 0 {
+		return nil
+	}
+	f, err := r.Data(chunk.Hasher{Algorithm: r.ID.Algorithm})
+	if err != nil {
+		return err
+	}
+	f.Release()
+	return nil
+}
+
+// PutChunk validates the encoded chunk.Record p and stores it. The ID of a
+// whole plain Record is verified unless it is keyed, so that no client can
+// store other data under the ID of a chunk of the other clients. It returns
+// whether it was not stored yet.
+func (s *Service) PutChunk(ctx context.Context, p []byte) (bool, error) {
+	var r chunk.Record
+	rd := bytes.NewReader(p)
+	if _, err := r.ReadFrom(rd); err != nil {
+		return false, fmt.Errorf("%w: %v", vault.ErrInvalid, err)
+	}
+	err := verify(&r)
+	r.Release()
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", vault.ErrInvalid, err)
+	}
+	if rd.Len() > 0 {
+		return false, fmt.Errorf("%w: trailing data", vault.ErrInvalid)
+	}
+	s.gc.RLock()
+	defer s.gc.RUnlock()
+	stored, err := s.Backend.PutChunk(ctx, r.ID, p)
+	if err == nil {
+		s.pin(r.ID)
+	}
+	if err == nil && s.Stats != nil {
+		if stored {
+			s.Stats.Counter(stats.ChunksStored).Add(1)
+			s.Stats.Counter(stats.StoredBytes).Add(int64(len(p)))
+		} else {
+			s.Stats.Counter(stats.ChunksDeduplicated).Add(1)
+		}
+	}
+	return stored, err
+}
+
+// GetChunk returns the encoded chunk.Record with the id.
+func (s *Service) GetChunk(ctx context.Context, id chunk.ID) ([]byte, error) {
+	p, err := s.Backend.GetChunk(ctx, id)
+	return p, notFound(err)
+}
+
+// HasChunks returns whether each of the ids is stored.
+func (s *Service) HasChunks(ctx context.Context, ids []chunk.ID) ([]bool, error) {
+	s.gc.RLock()
+	defer s.gc.RUnlock()
+	has, err := s.Backend.HasChunks(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var found []chunk.ID
+	for i, id := range ids {
+		if has[i] {
+			found = append(found, id)
+		}
+	}
+	s.pin(found...)
+	return has, nil
+}
+
+// Collect deletes the chunks that are not referenced by any Archive and
+// returns how many. It fails with ErrBusy while any Archive is Open, and it
+// excludes the methods that may add references meanwhile. It spares the
+// chunks stored or found within the Grace, because the clients may reference
+// them in the Archives that they begin next, and it deletes none within the
+// Grace since its first call or the first chunk stored or found, because the
+// process may have restarted in the middle of an upload.
+func (s *Service) Collect(ctx context.Context) (int, error) {
+	s.gc.Lock()
+	defer s.gc.Unlock()
+	now := time.Now()
+	s.pins.Lock()
+	defer s.pins.Unlock()
+	s.pins.init(now)
+	reachable := make(map[chunk.ID]struct{})
+	if err := s.Backend.View(ctx, func(tx Tx) error {
+		if err := tx.Scan(archivePrefix, func(key string, p []byte) error {
+			var a vault.Archive
+			if err := json.Unmarshal(p, &a); err != nil {
+				return err
+			}
+			if a.State == vault.Open {
+				return ErrBusy
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		return tx.Scan(entryPrefix, func(key string, p []byte) error {
+			var entries []vault.Entry
+			if err := json.Unmarshal(p, &entries); err != nil {
+				return err
+			}
+			for _, e := range entries {
+				for _, c := range e.Chunks {
+					reachable[c.ID] = struct{}{}
+				}
+			}
+			return nil
+		})
+	}); err != nil {
+		return 0, err
+	}
+	since := now.Add(-s.grace())
+	s.pins.prune(since)
+	if s.pins.start.After(since) {
+		return 0, nil
+	}
+	var garbage []chunk.ID
+	if err := s.Backend.Chunks(ctx, func(id chunk.ID) error {
+		_, ok := reachable[id]
+		_, pinned := s.pins.ids[id]
+		if !ok && !pinned {
+			garbage = append(garbage, id)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	for i, id := range garbage {
+		if err := s.Backend.DeleteChunk(ctx, id); err != nil && err != ErrNotFound {
+			return i, err
+		}
+	}
+	return len(garbage), nil
+}
+
+// idLess orders the ids by their binary form.
+func idLess(a, b chunk.ID) bool {
+	if a.Algorithm != b.Algorithm {
+		return a.Algorithm < b.Algorithm
+	}
+	return bytes.Compare(a.Sum[:], b.Sum[:]) < 0
+}
+
+// SortIDs sorts the ids in the order of Backend.Chunks.
+func SortIDs(ids []chunk.ID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return idLess(ids[i], ids[j])
+	})
+}
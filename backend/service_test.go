@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+func TestTimeKey(t *testing.T) {
+	t1 := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("", 3600))
+	if k := timeKey(t1); k != "20240102T020405.000000006Z" {
+		t.Error(k)
+	}
+	for i, c := range []struct{ a, b time.Time }{
+		{t1, t1.Add(time.Nanosecond)},                         // 0
+		{t1.Add(999 * time.Millisecond), t1.Add(time.Second)}, // 1
+		{t1, t1.AddDate(10, 0, 0)},                            // 2
+	} {
+		if !(timeKey(c.a) < timeKey(c.b)) {
+			t.Error(i, timeKey(c.a), timeKey(c.b))
+		}
+	}
+}
+
+func TestSortIDs(t *testing.T) {
+	a := chunk.ID{Algorithm: chunk.SHA256}
+	b := a
+	b.Sum[31] = 1
+	c := a
+	c.Sum[0] = 1
+	d := chunk.ID{Algorithm: chunk.SHA512_256}
+	ids := []chunk.ID{d, c, a, b}
+	SortIDs(ids)
+	for i, id := range []chunk.ID{a, b, c, d} {
+		if ids[i] != id {
+			t.Error(i, ids[i])
+		}
+	}
+}
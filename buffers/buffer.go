@@ -2,6 +2,7 @@ package buffers
 
 import (
 	"crypto/sha256"
+	"hash"
 	"hash/fnv"
 )
 
@@ -21,20 +22,21 @@ func (f Buffers) Append(q ...[]byte) Buffers {
 	return f
 }
 
-// Hash32 appends the FNV-1a hash of f to q and returns the resulting slice.
-func (f Buffers) Hash32(q []byte) []byte {
-	d := fnv.New32a()
+// Hash writes all slices of f to d and appends its sum to q and returns the
+// resulting slice.
+func (f Buffers) Hash(d hash.Hash, q []byte) []byte {
 	for _, p := range f.S {
 		d.Write(p)
 	}
 	return d.Sum(q)
 }
 
+// Hash32 appends the FNV-1a hash of f to q and returns the resulting slice.
+func (f Buffers) Hash32(q []byte) []byte {
+	return f.Hash(fnv.New32a(), q)
+}
+
 // Hash256 appends the SHA 256 hash of f to q and returns the resulting slice.
 func (f Buffers) Hash256(q []byte) []byte {
-	d := sha256.New()
-	for _, p := range f.S {
-		d.Write(p)
-	}
-	return d.Sum(q)
+	return f.Hash(sha256.New(), q)
 }
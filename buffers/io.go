@@ -0,0 +1,114 @@
+package buffers
+
+import (
+	"io"
+	"net"
+)
+
+// WriteTo writes all slices of f to w with net.Buffers, so that a vectored
+// write is used when w is a connection that supports it. f is not modified.
+func (f Buffers) WriteTo(w io.Writer) (int64, error) {
+	b := make(net.Buffers, len(f.S))
+	copy(b, f.S)
+	return b.WriteTo(w)
+}
+
+// ReadFrom reads r until EOF and appends the data to f in segments of length
+// SegmentSize, except the last one that may be shorter. If the last slice of
+// f ends where a previous ReadFrom stopped filling its segment, the rest of
+// that segment is filled first. It returns the number of bytes read and any
+// error other than io.EOF. The segments are taken from a pool within the
+// limits of the budget, see SetBudget and Release, and a segment is returned
+// at once if nothing is read into it.
+func (f *Buffers) ReadFrom(r io.Reader) (n int64, err error) {
+	if k := len(f.S); k > 0 {
+		if q := spare(f.S[k-1]); q != nil {
+			m, err := io.ReadFull(r, q)
+			p := extend(f.S[k-1], m)
+			// The slices of f may be shared, so the last one is not replaced
+			// in place.
+			f.S = append(f.S[:k-1:k-1], p)
+			f.N += m
+			n += int64(m)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return n, nil
+			}
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	for {
+		p := newSegment()
+		m, err := io.ReadFull(r, p)
+		fill(p, m)
+		if m > 0 {
+			*f = f.Append(p[:m])
+			n += int64(m)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// Reader is an io.Reader and io.WriterTo of the bytes of a Buffers.
+type Reader struct {
+	s   [][]byte
+	off int
+	n   int
+}
+
+// NewReader returns a Reader of f. f is not modified.
+func NewReader(f Buffers) *Reader {
+	return &Reader{f.S, 0, f.N}
+}
+
+// Len returns the number of bytes not read yet.
+func (r *Reader) Len() int {
+	return r.n
+}
+
+// Read copies the next bytes to p.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	if r.n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	for len(p) > 0 && r.n > 0 {
+		m := copy(p, r.s[0][r.off:])
+		p, n = p[m:], n+m
+		r.skip(m)
+	}
+	return
+}
+
+// WriteTo writes the bytes not read yet to w like Buffers.WriteTo.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	if r.n == 0 {
+		return 0, nil
+	}
+	b := make(net.Buffers, len(r.s))
+	copy(b, r.s)
+	b[0] = b[0][r.off:]
+	n, err := b.WriteTo(w)
+	r.skip(int(n))
+	return n, err
+}
+
+func (r *Reader) skip(n int) {
+	r.n -= n
+	for n > 0 {
+		m := len(r.s[0]) - r.off
+		if n < m {
+			r.off += n
+			return
+		}
+		n, r.s, r.off = n-m, r.s[1:], 0
+	}
+	for len(r.s) > 0 && r.off == len(r.s[0]) {
+		r.s, r.off = r.s[1:], 0
+	}
+}
@@ -0,0 +1,124 @@
+package buffers
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestBuffers_WriteTo(t *testing.T) {
+	f := Buffers{}.Append([]byte("ab"), []byte{}, []byte("cde"))
+	buf := bytes.Buffer{}
+	if n, err := f.WriteTo(&buf); err != nil {
+		t.Error(err)
+	} else if n != 5 {
+		t.Error(n)
+	}
+	if s := buf.String(); s != "abcde" {
+		t.Error(s)
+	}
+	if l := len(f.S[2]); l != 3 {
+		t.Error(l)
+	}
+}
+
+func TestBuffers_ReadFrom(t *testing.T) {
+	p := bytes.Repeat([]byte("abcdefgh"), SegmentSize/4+1)
+	f := Buffers{}.Append([]byte("x"))
+	if n, err := f.ReadFrom(iotest.HalfReader(bytes.NewReader(p))); err != nil {
+		t.Error(err)
+	} else if n != int64(len(p)) {
+		t.Error(n)
+	}
+	if n := f.N; n != len(p)+1 {
+		t.Error(n)
+	}
+	if l := len(f.S); l != 4 {
+		t.Error(l)
+	} else if l := len(f.S[1]); l != SegmentSize {
+		t.Error(l)
+	} else if l := len(f.S[3]); l != 8 {
+		t.Error(l)
+	}
+	buf := bytes.Buffer{}
+	f.WriteTo(&buf)
+	if !bytes.Equal(buf.Bytes(), append([]byte("x"), p...)) {
+		t.Error(buf.Len())
+	}
+}
+
+func TestBuffers_ReadFrom_Error(t *testing.T) {
+	f := Buffers{}
+	if _, err := f.ReadFrom(iotest.ErrReader(io.ErrClosedPipe)); err != io.ErrClosedPipe {
+		t.Error(err)
+	}
+	if n := f.N; n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestReader(t *testing.T) {
+	f := Buffers{}.Append([]byte("ab"), []byte{}, []byte("cde"), []byte("f"))
+	if err := iotest.TestReader(NewReader(f), []byte("abcdef")); err != nil {
+		t.Error(err)
+	}
+	r := NewReader(f)
+	p := make([]byte, 3)
+	if n, err := r.Read(p); err != nil {
+		t.Error(err)
+	} else if n != 3 || string(p) != "abc" {
+		t.Error(n, string(p))
+	}
+	if n := r.Len(); n != 3 {
+		t.Error(n)
+	}
+	buf := bytes.Buffer{}
+	if n, err := r.WriteTo(&buf); err != nil {
+		t.Error(err)
+	} else if n != 3 || buf.String() != "def" {
+		t.Error(n, buf.String())
+	}
+	if n, err := r.Read(p); err != io.EOF {
+		t.Error(n, err)
+	}
+	if s := string(f.S[2]); s != "cde" {
+		t.Error(s)
+	}
+}
+
+func TestBuffers_ReadFrom_Spare(t *testing.T) {
+	n := InUse()
+	f := Buffers{}
+	if _, err := f.ReadFrom(strings.NewReader("abc")); err != nil {
+		t.Error(err)
+	}
+	g := f.Slice(0, 2)
+	if _, err := f.ReadFrom(strings.NewReader("def")); err != nil {
+		t.Error(err)
+	}
+	if _, err := g.ReadFrom(strings.NewReader("xyz")); err != nil {
+		t.Error(err)
+	}
+	if _, err := f.ReadFrom(strings.NewReader("")); err != nil {
+		t.Error(err)
+	}
+	if u := InUse() - n; u != 2*SegmentSize {
+		t.Error(u)
+	}
+	if l := len(f.S); l != 1 {
+		t.Error(l)
+	}
+	if s := string(f.Bytes()); s != "abcdef" {
+		t.Error(s)
+	}
+	if s := string(g.Bytes()); s != "abxyz" {
+		t.Error(s)
+	}
+	f.Release()
+	g.Release()
+	if u := InUse() - n; u != 0 {
+		t.Error(u)
+	}
+}
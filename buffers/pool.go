@@ -0,0 +1,170 @@
+package buffers
+
+import "sync"
+
+// SegmentSize is the length of the segments that ReadFrom allocates.
+const SegmentSize = 64 << 10
+
+var segments = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, SegmentSize)
+	},
+}
+
+// segment is the state of a segment p taken by ReadFrom.
+type segment struct {
+	p []byte
+
+	// refs is the number of slices of Buffers that refer to the segment.
+	refs int
+
+	// used is the number of bytes from its start that ReadFrom has filled.
+	used int
+}
+
+var budget = struct {
+	sync.Mutex
+	cond  sync.Cond
+	max   int64
+	inUse int64
+	owned map[*byte]*segment
+}{owned: make(map[*byte]*segment)}
+
+func init() {
+	budget.cond.L = &budget.Mutex
+}
+
+// SetBudget sets the maximum number of bytes that the segments taken by
+// ReadFrom may hold at the same time. ReadFrom blocks when the budget is
+// exhausted until Release frees enough segments, so the budget must be larger
+// than what a single caller holds plus one segment, which ReadFrom needs to
+// detect the end of the data. A value of zero or less disables it, which
+// is the default.
+func SetBudget(n int64) {
+	budget.Lock()
+	budget.max = n
+	budget.Unlock()
+	budget.cond.Broadcast()
+}
+
+// InUse returns the number of bytes of the segments taken by ReadFrom and not
+// released yet.
+func InUse() int64 {
+	budget.Lock()
+	defer budget.Unlock()
+	return budget.inUse
+}
+
+// key returns the address of the last byte of the array of p, which is the
+// same for all the slices of a segment, or nil if p has no capacity.
+func key(p []byte) *byte {
+	if cap(p) == 0 {
+		return nil
+	}
+	return &p[:cap(p)][cap(p)-1]
+}
+
+// newSegment takes a segment from the pool with no references.
+func newSegment() []byte {
+	budget.Lock()
+	for budget.max > 0 && budget.inUse+SegmentSize > budget.max {
+		budget.cond.Wait()
+	}
+	budget.inUse += SegmentSize
+	p := segments.Get().([]byte)
+	budget.owned[key(p)] = &segment{p: p}
+	budget.Unlock()
+	return p
+}
+
+// fill records that the first n bytes of p, a segment returned by newSegment,
+// are referred by a slice, or else it frees p if n is zero.
+func fill(p []byte, n int) {
+	budget.Lock()
+	k := key(p)
+	s := budget.owned[k]
+	if n > 0 {
+		s.refs, s.used = 1, n
+		budget.Unlock()
+		return
+	}
+	free(k, s)
+}
+
+// end returns the offset of the end of p in its segment.
+func end(p []byte) int {
+	return SegmentSize - cap(p) + len(p)
+}
+
+// spare returns the bytes of the segment of p after p if ReadFrom has not
+// filled them, or else nil. They are claimed until extend is called.
+func spare(p []byte) []byte {
+	budget.Lock()
+	defer budget.Unlock()
+	s := budget.owned[key(p)]
+	if s == nil || end(p) != s.used || s.used == SegmentSize {
+		return nil
+	}
+	q := s.p[s.used:]
+	s.used = SegmentSize
+	return q
+}
+
+// extend returns p extended with the first n bytes of its spare bytes and
+// releases the others.
+func extend(p []byte, n int) []byte {
+	budget.Lock()
+	budget.owned[key(p)].used = end(p) + n
+	budget.Unlock()
+	return p[:len(p)+n]
+}
+
+// free returns the segment s with the key k to the pool. budget must be
+// locked, and it is unlocked.
+func free(k *byte, s *segment) {
+	delete(budget.owned, k)
+	budget.inUse -= SegmentSize
+	budget.Unlock()
+	budget.cond.Broadcast()
+	segments.Put(s.p)
+}
+
+// ref adds a reference to the segment of p, if any.
+func ref(p []byte) {
+	budget.Lock()
+	if s := budget.owned[key(p)]; s != nil {
+		s.refs++
+	}
+	budget.Unlock()
+}
+
+// unref removes a reference to the segment of p, if any, and returns it to
+// the pool when it has none.
+func unref(p []byte) {
+	budget.Lock()
+	k := key(p)
+	s := budget.owned[k]
+	if s == nil {
+		budget.Unlock()
+		return
+	}
+	if s.refs--; s.refs > 0 {
+		budget.Unlock()
+		return
+	}
+	free(k, s)
+}
+
+// Release removes the references of the slices of f to the segments taken by
+// ReadFrom, and every segment that has none is returned to the pool and freed
+// from the budget. The other slices of f are ignored. Each slice of a segment
+// in a Buffers holds a reference, which is added by ReadFrom and Slice, and
+// which SplitAt moves to its results and adds for the slice that it splits in
+// two. f may not be used afterwards, but other Buffers that share its segments
+// may, until they are released too. Releasing f twice is a bug: the pool may
+// have given its segments to a new owner, whose references would be removed.
+func (f Buffers) Release() {
+	for _, p := range f.S {
+		unref(p)
+	}
+}
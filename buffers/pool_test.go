@@ -0,0 +1,82 @@
+package buffers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBuffers_Release(t *testing.T) {
+	n := InUse()
+	f := Buffers{}.Append([]byte("x"))
+	f.ReadFrom(bytes.NewReader(make([]byte, SegmentSize+1)))
+	if u := InUse() - n; u != 2*SegmentSize {
+		t.Error(u)
+	}
+	g, r := f.SplitAt(1 + SegmentSize)
+	g.Release()
+	if u := InUse() - n; u != SegmentSize {
+		t.Error(u)
+	}
+	r.Release()
+	if u := InUse() - n; u != 0 {
+		t.Error(u)
+	}
+}
+
+func TestBuffers_Release_Shared(t *testing.T) {
+	n := InUse()
+	f := Buffers{}
+	f.ReadFrom(bytes.NewReader(bytes.Repeat([]byte("ab"), 100)))
+	g, r := f.SplitAt(50)
+	h := r.Slice(10, 20)
+	g.Release()
+	if u := InUse() - n; u != SegmentSize {
+		t.Error(u)
+	}
+	r.Release()
+	if u := InUse() - n; u != SegmentSize {
+		t.Error(u)
+	}
+	if s := string(h.Bytes()); s != "ababababab" {
+		t.Error(s)
+	}
+	h.Release()
+	if u := InUse() - n; u != 0 {
+		t.Error(u)
+	}
+	k := Buffers{}
+	k.ReadFrom(bytes.NewReader(make([]byte, 10)))
+	if u := InUse() - n; u != SegmentSize {
+		t.Error(u)
+	}
+	k.Release()
+}
+
+func TestSetBudget(t *testing.T) {
+	defer SetBudget(0)
+	SetBudget(InUse() + 2*SegmentSize)
+	f := Buffers{}
+	f.ReadFrom(bytes.NewReader(make([]byte, SegmentSize+1)))
+	done := make(chan Buffers)
+	go func() {
+		g := Buffers{}
+		g.ReadFrom(bytes.NewReader([]byte("a")))
+		done <- g
+	}()
+	select {
+	case <-done:
+		t.Error("not blocked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	f.Release()
+	select {
+	case g := <-done:
+		if s := string(g.Bytes()); s != "a" {
+			t.Error(s)
+		}
+		g.Release()
+	case <-time.After(time.Second):
+		t.Error("blocked")
+	}
+}
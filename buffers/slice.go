@@ -0,0 +1,122 @@
+package buffers
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Slice returns the Buffers that represents the bytes of f in the range
+// [i, j). The slices of f are shared, not copied, and g holds its own
+// references to the segments, so both f and g must be released. It panics if
+// the range is out of bounds.
+func (f Buffers) Slice(i, j int) (g Buffers) {
+	defer func() {
+		for _, p := range g.S {
+			ref(p)
+		}
+	}()
+	if i < 0 || j < i || j > f.N {
+		panic(fmt.Sprintf("buffers: slice bounds out of range [%d:%d] with length %d", i, j, f.N))
+	}
+	if i == j {
+		return
+	}
+	for _, p := range f.S {
+		if i >= len(p) {
+			i, j = i-len(p), j-len(p)
+			continue
+		}
+		if j <= len(p) {
+			return g.Append(p[i:j])
+		}
+		g = g.Append(p[i:])
+		i, j = 0, j-len(p)
+	}
+	return
+}
+
+// SplitAt returns the Buffers with the bytes of f before and after the offset
+// i. The slices of f are shared, not copied, and their references to the
+// segments are moved to g and r, so f may not be released, but g and r must.
+// It panics if i is out of bounds.
+func (f Buffers) SplitAt(i int) (g Buffers, r Buffers) {
+	if i < 0 || i > f.N {
+		panic(fmt.Sprintf("buffers: split offset %d out of range with length %d", i, f.N))
+	}
+	for k, p := range f.S {
+		if i < len(p) {
+			if i > 0 {
+				ref(p)
+				g = g.Append(p[:i])
+				r = r.Append(p[i:])
+			} else {
+				r = r.Append(p)
+			}
+			r = r.Append(f.S[k+1:]...)
+			return
+		}
+		g = g.Append(p)
+		i -= len(p)
+	}
+	return
+}
+
+// At returns the byte at the offset i. It panics if i is out of bounds.
+func (f Buffers) At(i int) byte {
+	if i >= 0 {
+		for _, p := range f.S {
+			if i < len(p) {
+				return p[i]
+			}
+			i -= len(p)
+		}
+	}
+	panic(fmt.Sprintf("buffers: index %d out of range with length %d", i, f.N))
+}
+
+// Equal returns whether f and g represent the same bytes, regardless of how
+// they are partitioned in slices.
+func (f Buffers) Equal(g Buffers) bool {
+	if f.N != g.N {
+		return false
+	}
+	var p, q []byte
+	for i, j := 0, 0; ; {
+		for len(p) == 0 && i < len(f.S) {
+			p, i = f.S[i], i+1
+		}
+		for len(q) == 0 && j < len(g.S) {
+			q, j = g.S[j], j+1
+		}
+		if len(p) == 0 || len(q) == 0 {
+			return len(p) == len(q)
+		}
+		n := len(p)
+		if len(q) < n {
+			n = len(q)
+		}
+		if !bytes.Equal(p[:n], q[:n]) {
+			return false
+		}
+		p, q = p[n:], q[n:]
+	}
+}
+
+// Copy copies the first bytes of f to p and returns the number of bytes
+// copied, which is the minimum of f.N and len(p).
+func (f Buffers) Copy(p []byte) (n int) {
+	for _, q := range f.S {
+		if n == len(p) {
+			break
+		}
+		n += copy(p[n:], q)
+	}
+	return
+}
+
+// Bytes returns a new []byte with all bytes of f.
+func (f Buffers) Bytes() []byte {
+	p := make([]byte, f.N)
+	f.Copy(p)
+	return p
+}
@@ -0,0 +1,105 @@
+package buffers
+
+import (
+	"fmt"
+	"testing"
+)
+
+var testSlices = Buffers{}.Append([]byte("ab"), []byte{}, []byte("cde"), []byte("f"))
+
+func TestBuffers_Slice(t *testing.T) {
+	s := "abcdef"
+	for i := 0; i <= len(s); i++ {
+		for j := i; j <= len(s); j++ {
+			g := testSlices.Slice(i, j)
+			if n := g.N; n != j-i {
+				t.Error(i, j, n)
+			}
+			if b := string(g.Bytes()); b != s[i:j] {
+				t.Error(i, j, b)
+			}
+		}
+	}
+	if g := testSlices.Slice(2, 5); len(g.S) != 1 {
+		t.Error(len(g.S))
+	} else if &g.S[0][0] != &testSlices.S[2][0] {
+		t.Error("copied")
+	}
+}
+
+func TestBuffers_Slice_Panic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error(r)
+		} else if s := fmt.Sprint(r); s != "buffers: slice bounds out of range [2:7] with length 6" {
+			t.Error(s)
+		}
+	}()
+	testSlices.Slice(2, 7)
+}
+
+func TestBuffers_SplitAt(t *testing.T) {
+	s := "abcdef"
+	for i := 0; i <= len(s); i++ {
+		g, r := testSlices.SplitAt(i)
+		if n := g.N; n != i {
+			t.Error(i, n)
+		} else if n := r.N; n != len(s)-i {
+			t.Error(i, n)
+		}
+		if b := string(g.Bytes()); b != s[:i] {
+			t.Error(i, b)
+		} else if b := string(r.Bytes()); b != s[i:] {
+			t.Error(i, b)
+		}
+	}
+}
+
+func TestBuffers_At(t *testing.T) {
+	s := "abcdef"
+	for i := range s {
+		if b := testSlices.At(i); b != s[i] {
+			t.Error(i, b)
+		}
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error(r)
+		}
+	}()
+	testSlices.At(6)
+}
+
+func TestBuffers_Equal(t *testing.T) {
+	if !testSlices.Equal(Buffers{}.Append([]byte("abcdef"))) {
+		t.Error()
+	}
+	if !testSlices.Equal(Buffers{}.Append([]byte("a"), []byte("bcd"), []byte{}, []byte("ef"))) {
+		t.Error()
+	}
+	if testSlices.Equal(Buffers{}.Append([]byte("abcdeg"))) {
+		t.Error()
+	}
+	if testSlices.Equal(Buffers{}.Append([]byte("abcde"))) {
+		t.Error()
+	}
+	if !(Buffers{}).Equal(Buffers{}.Append([]byte{})) {
+		t.Error()
+	}
+}
+
+func TestBuffers_Copy(t *testing.T) {
+	p := make([]byte, 4)
+	if n := testSlices.Copy(p); n != 4 {
+		t.Error(n)
+	} else if s := string(p); s != "abcd" {
+		t.Error(s)
+	}
+	p = make([]byte, 8)
+	if n := testSlices.Copy(p); n != 6 {
+		t.Error(n)
+	}
+	if s := string(testSlices.Bytes()); s != "abcdef" {
+		t.Error(s)
+	}
+}
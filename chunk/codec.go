@@ -0,0 +1,195 @@
+package chunk
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/lzw"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+// Codec is the compression of the Payload of a Record.
+type Codec uint8
+
+// The Codecs of the standard library.
+const (
+	// None is the Codec of an uncompressed Payload.
+	None Codec = 0
+
+	// Flate is compress/flate with the default compression level.
+	Flate Codec = 1
+
+	// Gzip is compress/gzip with the default compression level.
+	Gzip Codec = 2
+
+	// LZW is compress/lzw with the LSB order and 8 bit literals.
+	LZW Codec = 3
+)
+
+var codecs = []string{
+	None:  "none",
+	Flate: "flate",
+	Gzip:  "gzip",
+	LZW:   "lzw",
+}
+
+// ErrCodec is returned for an unknown Codec.
+var ErrCodec = errors.New("chunk: unknown codec")
+
+// Valid returns whether c is a known Codec.
+func (c Codec) Valid() bool {
+	return int(c) < len(codecs)
+}
+
+func (c Codec) String() string {
+	if c.Valid() {
+		return codecs[c]
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(c))
+}
+
+// ParseCodec returns the Codec with the name s.
+func ParseCodec(s string) (Codec, error) {
+	for c, n := range codecs {
+		if n == s {
+			return Codec(c), nil
+		}
+	}
+	return 0, ErrCodec
+}
+
+// MarshalText returns the name of c.
+func (c Codec) MarshalText() ([]byte, error) {
+	if !c.Valid() {
+		return nil, ErrCodec
+	}
+	return []byte(codecs[c]), nil
+}
+
+// UnmarshalText sets c to the Codec with the name p.
+func (c *Codec) UnmarshalText(p []byte) (err error) {
+	*c, err = ParseCodec(string(p))
+	return
+}
+
+func (c Codec) writer(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case Flate:
+		return flate.NewWriter(w, flate.DefaultCompression)
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case LZW:
+		return lzw.NewWriter(w, lzw.LSB, 8), nil
+	}
+	return nil, ErrCodec
+}
+
+func (c Codec) reader(r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case Flate:
+		return flate.NewReader(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case LZW:
+		return lzw.NewReader(r, lzw.LSB, 8), nil
+	}
+	return nil, ErrCodec
+}
+
+// Compress returns f compressed with c, or f itself if c is None.
+func (c Codec) Compress(f buffers.Buffers) (buffers.Buffers, error) {
+	if c == None {
+		return f, nil
+	}
+	var b bytes.Buffer
+	w, err := c.writer(&b)
+	if err != nil {
+		return buffers.Buffers{}, err
+	}
+	if _, err := f.WriteTo(w); err != nil {
+		return buffers.Buffers{}, err
+	}
+	if err := w.Close(); err != nil {
+		return buffers.Buffers{}, err
+	}
+	return buffers.Buffers{}.Append(b.Bytes()), nil
+}
+
+// Decompress returns f decompressed with c, or a Slice of all of f if c is
+// None. An error that wraps ErrRecord is returned if the result is not length
+// bytes long, and no more than length + 1 bytes are ever decompressed. The
+// result is read into segments of buffers.Buffers.ReadFrom as the stream is
+// decompressed, and it must be released.
+func (c Codec) Decompress(f buffers.Buffers, length int) (buffers.Buffers, error) {
+	if c == None {
+		if f.N != length {
+			return buffers.Buffers{}, fmt.Errorf("%w: %d bytes for length %d", ErrRecord, f.N, length)
+		}
+		return f.Slice(0, f.N), nil
+	}
+	r, err := c.reader(buffers.NewReader(f))
+	if err != nil {
+		return buffers.Buffers{}, fmt.Errorf("%w: %v", ErrRecord, err)
+	}
+	defer r.Close()
+	var g buffers.Buffers
+	n, err := g.ReadFrom(io.LimitReader(r, int64(length)+1))
+	if err == nil && n > int64(length) {
+		err = errors.New("too long")
+	}
+	if err == nil && n != int64(length) {
+		err = fmt.Errorf("%d bytes for length %d", n, length)
+	}
+	if err != nil {
+		g.Release()
+		return buffers.Buffers{}, fmt.Errorf("%w: %v", ErrRecord, err)
+	}
+	return g, nil
+}
+
+// NewRecord returns the plain Record of data with its ID computed by h and
+// its Payload compressed with c, unless it does not shrink, like already
+// compressed media, and then the Codec is None. The ID is always computed
+// over the uncompressed data, so that the deduplication does not depend on
+// the Codec.
+func NewRecord(h Hasher, data buffers.Buffers, c Codec) (r Record, err error) {
+	if !c.Valid() {
+		return r, ErrCodec
+	}
+	if r.ID, err = h.Sum(data); err != nil {
+		return
+	}
+	r.Length, r.Payload = data.N, data
+	if c == None {
+		return
+	}
+	p, err := c.Compress(data)
+	if err != nil {
+		return
+	}
+	if p.N < data.N {
+		r.Codec, r.Payload = c, p
+	}
+	return
+}
+
+// Data returns the data of a plain Record after decompressing its Payload and
+// verifying its ID with h. The data must be released, like the Record.
+func (r *Record) Data(h Hasher) (buffers.Buffers, error) {
+	if r.Cipher != Plain || r.Parity.Data > 0 {
+		return buffers.Buffers{}, fmt.Errorf("%w: not the whole plain data", ErrRecord)
+	}
+	f, err := r.Codec.Decompress(r.Payload, r.Length)
+	if err != nil {
+		return f, err
+	}
+	if err := h.Verify(r.ID, f); err != nil {
+		f.Release()
+		return buffers.Buffers{}, err
+	}
+	return f, nil
+}
@@ -0,0 +1,139 @@
+package chunk
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+func TestCodec(t *testing.T) {
+	for i, s := range []string{"none", "flate", "gzip", "lzw"} {
+		c, err := ParseCodec(s)
+		if err != nil || c != Codec(i) || c.String() != s {
+			t.Error(i, c, err)
+		}
+	}
+	if _, err := ParseCodec("zstd"); err != ErrCodec {
+		t.Error(err)
+	}
+	if s := Codec(9).String(); s != "unknown(9)" {
+		t.Error(s)
+	}
+	var c Codec
+	if err := c.UnmarshalText([]byte("gzip")); err != nil || c != Gzip {
+		t.Error(c, err)
+	}
+	if _, err := Codec(9).MarshalText(); err != ErrCodec {
+		t.Error(err)
+	}
+}
+
+func TestCodec_Compress(t *testing.T) {
+	text := bytes.Repeat([]byte("floc chunk "), 10000)
+	f := buffers.Buffers{}.Append(text[:50000]).Append(text[50000:])
+	for _, c := range []Codec{None, Flate, Gzip, LZW} {
+		g, err := c.Compress(f)
+		if err != nil {
+			t.Fatal(c, err)
+		}
+		if c != None && g.N >= f.N/4 {
+			t.Error(c, g.N)
+		}
+		h, err := c.Decompress(g, f.N)
+		if err != nil {
+			t.Fatal(c, err)
+		}
+		if !h.Equal(f) {
+			t.Error(c)
+		}
+		h.Release()
+		if _, err := c.Decompress(g, f.N-1); !errors.Is(err, ErrRecord) {
+			t.Error(c, err)
+		}
+		if _, err := c.Decompress(g, f.N+1); !errors.Is(err, ErrRecord) {
+			t.Error(c, err)
+		}
+		if c != None {
+			if _, err := c.Decompress(buffers.Buffers{}.Append(text[:100]), f.N); !errors.Is(err, ErrRecord) {
+				t.Error(c, err)
+			}
+		}
+		empty, err := c.Compress(buffers.Buffers{})
+		if err != nil {
+			t.Error(c, err)
+		}
+		if h, err := c.Decompress(empty, 0); err != nil || h.N != 0 {
+			t.Error(c, h.N, err)
+		}
+		if c != None {
+			n := buffers.InUse()
+			if _, err := c.Decompress(g, MaxLength); !errors.Is(err, ErrRecord) {
+				t.Error(c, err)
+			}
+			if u := buffers.InUse() - n; u != 0 {
+				t.Error(c, u)
+			}
+		}
+	}
+	if _, err := Codec(9).Compress(f); err != ErrCodec {
+		t.Error(err)
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	h := Hasher{Algorithm: SHA256}
+	text := buffers.Buffers{}.Append(bytes.Repeat([]byte("floc "), 1000))
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(random)
+	for i, c := range []struct {
+		data  buffers.Buffers
+		codec Codec
+		want  Codec
+	}{
+		{text, Flate, Flate},                           // 0
+		{text, None, None},                             // 1
+		{buffers.Buffers{}.Append(random), Gzip, None}, // 2
+		{text, LZW, LZW},                               // 3
+	} {
+		r, err := NewRecord(h, c.data, c.codec)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if r.Codec != c.want || r.Length != c.data.N {
+			t.Error(i, r.Codec, r.Length)
+		}
+		if id, _ := h.Sum(c.data); r.ID != id {
+			t.Error(i, r.ID)
+		}
+		var buf bytes.Buffer
+		if _, err := r.WriteTo(&buf); err != nil {
+			t.Fatal(i, err)
+		}
+		var s Record
+		if _, err := s.ReadFrom(&buf); err != nil {
+			t.Fatal(i, err)
+		}
+		f, err := s.Data(h)
+		if err != nil {
+			t.Error(i, err)
+		} else if !f.Equal(c.data) {
+			t.Error(i)
+		}
+		f.Release()
+		s.Release()
+	}
+	r, err := NewRecord(h, text, Flate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ID.Sum[0]++
+	if _, err := r.Data(h); err != ErrMismatch {
+		t.Error(err)
+	}
+	if _, err := NewRecord(h, text, 9); err != ErrCodec {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package chunk identifies and encodes the chunks in which data streams are
+// splitted.
+package chunk
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+// Algorithm is the hash function that computes an ID.
+type Algorithm uint8
+
+// The Algorithms. The zero value is not valid.
+const (
+	// SHA256 is the plain SHA 256 of the data.
+	SHA256 Algorithm = 1
+
+	// HMACSHA256 is the HMAC SHA 256 of the data with a secret key, so that
+	// the IDs do not disclose whether some known data is stored.
+	HMACSHA256 Algorithm = 2
+
+	// SHA512_256 is the SHA 512/256 of the data, faster than SHA256 on 64
+	// bit platforms.
+	SHA512_256 Algorithm = 3
+)
+
+var algorithms = []string{
+	SHA256:     "sha256",
+	HMACSHA256: "hmac-sha256",
+	SHA512_256: "sha512-256",
+}
+
+// ErrAlgorithm is returned for an unknown Algorithm.
+var ErrAlgorithm = errors.New("chunk: unknown algorithm")
+
+// ErrKey is returned when a keyed Algorithm has no key or an unkeyed one has
+// a key.
+var ErrKey = errors.New("chunk: invalid key for algorithm")
+
+// Valid returns whether a is a known Algorithm.
+func (a Algorithm) Valid() bool {
+	return int(a) < len(algorithms) && algorithms[a] != ""
+}
+
+// Keyed returns whether a requires a secret key.
+func (a Algorithm) Keyed() bool {
+	return a == HMACSHA256
+}
+
+func (a Algorithm) String() string {
+	if a.Valid() {
+		return algorithms[a]
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(a))
+}
+
+// ParseAlgorithm returns the Algorithm with the name s.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	for a, n := range algorithms {
+		if n != "" && n == s {
+			return Algorithm(a), nil
+		}
+	}
+	return 0, ErrAlgorithm
+}
+
+// MarshalText returns the name of a.
+func (a Algorithm) MarshalText() ([]byte, error) {
+	if !a.Valid() {
+		return nil, ErrAlgorithm
+	}
+	return []byte(algorithms[a]), nil
+}
+
+// UnmarshalText sets a to the Algorithm with the name p.
+func (a *Algorithm) UnmarshalText(p []byte) (err error) {
+	*a, err = ParseAlgorithm(string(p))
+	return
+}
+
+// Size is the length of the sums of all Algorithms.
+const Size = 32
+
+// ID identifies a chunk by the Sum of its data computed with the Algorithm.
+// It is self-describing: its text form is the name of the Algorithm and the
+// hexadecimal Sum separated by a colon, and its binary form is the Algorithm
+// byte followed by the Sum.
+type ID struct {
+	Algorithm Algorithm
+	Sum       [Size]byte
+}
+
+// ErrID is returned when an ID cannot be decoded.
+var ErrID = errors.New("chunk: invalid id")
+
+// IsZero returns whether id is the zero value.
+func (id ID) IsZero() bool {
+	return id == ID{}
+}
+
+func (id ID) String() string {
+	return id.Algorithm.String() + ":" + hex.EncodeToString(id.Sum[:])
+}
+
+// ParseID decodes the text form of an ID.
+func ParseID(s string) (id ID, err error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return id, ErrID
+	}
+	if id.Algorithm, err = ParseAlgorithm(s[:i]); err != nil {
+		return
+	}
+	if hex.DecodedLen(len(s)-i-1) != Size {
+		return ID{}, ErrID
+	}
+	if _, err = hex.Decode(id.Sum[:], []byte(s[i+1:])); err != nil {
+		return ID{}, ErrID
+	}
+	return
+}
+
+// MarshalText returns the text form of id.
+func (id ID) MarshalText() ([]byte, error) {
+	if !id.Algorithm.Valid() {
+		return nil, ErrAlgorithm
+	}
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes the text form of an ID.
+func (id *ID) UnmarshalText(p []byte) (err error) {
+	*id, err = ParseID(string(p))
+	return
+}
+
+// MarshalBinary returns the binary form of id.
+func (id ID) MarshalBinary() ([]byte, error) {
+	if !id.Algorithm.Valid() {
+		return nil, ErrAlgorithm
+	}
+	return append([]byte{byte(id.Algorithm)}, id.Sum[:]...), nil
+}
+
+// UnmarshalBinary decodes the binary form of an ID.
+func (id *ID) UnmarshalBinary(p []byte) error {
+	if len(p) != 1+Size {
+		return ErrID
+	}
+	if a := Algorithm(p[0]); !a.Valid() {
+		return ErrAlgorithm
+	}
+	id.Algorithm = Algorithm(p[0])
+	copy(id.Sum[:], p[1:])
+	return nil
+}
+
+// Hasher computes IDs with the Algorithm and the Key, which is required by the
+// keyed Algorithms and forbidden by the others.
+type Hasher struct {
+	Algorithm Algorithm
+	Key       []byte
+}
+
+// New returns a new hash.Hash of the Algorithm.
+func (h Hasher) New() (hash.Hash, error) {
+	if !h.Algorithm.Valid() {
+		return nil, ErrAlgorithm
+	}
+	if h.Algorithm.Keyed() != (len(h.Key) > 0) {
+		return nil, ErrKey
+	}
+	switch h.Algorithm {
+	case HMACSHA256:
+		return hmac.New(sha256.New, h.Key), nil
+	case SHA512_256:
+		return sha512.New512_256(), nil
+	default:
+		return sha256.New(), nil
+	}
+}
+
+// Sum returns the ID of the data f.
+func (h Hasher) Sum(f buffers.Buffers) (id ID, err error) {
+	d, err := h.New()
+	if err != nil {
+		return
+	}
+	id.Algorithm = h.Algorithm
+	f.Hash(d, id.Sum[:0])
+	return
+}
+
+// Verify returns ErrMismatch if id is not the ID of the data f. Only the
+// holders of the Key can verify the IDs of a keyed Algorithm.
+func (h Hasher) Verify(id ID, f buffers.Buffers) error {
+	if id.Algorithm != h.Algorithm {
+		return ErrMismatch
+	}
+	id2, err := h.Sum(f)
+	if err != nil {
+		return err
+	}
+	if id2 != id {
+		return ErrMismatch
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package chunk
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+func TestAlgorithm(t *testing.T) {
+	for _, a := range []Algorithm{SHA256, HMACSHA256, SHA512_256} {
+		if b, err := ParseAlgorithm(a.String()); err != nil {
+			t.Error(err)
+		} else if b != a {
+			t.Error(b)
+		}
+	}
+	if _, err := ParseAlgorithm("md5"); err != ErrAlgorithm {
+		t.Error(err)
+	}
+	if s := Algorithm(0).String(); s != "unknown(0)" {
+		t.Error(s)
+	}
+	if s := Algorithm(9).String(); s != "unknown(9)" {
+		t.Error(s)
+	}
+}
+
+func TestHasher_Sum(t *testing.T) {
+	type entry struct {
+		given Hasher
+		then  string
+		err   error
+	}
+	f := buffers.Buffers{}.Append([]byte{0}, []byte{0})
+	for i, e := range []entry{
+		// 0
+		{Hasher{SHA256, nil},
+			"sha256:96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			nil},
+		// 1
+		{Hasher{HMACSHA256, []byte("key")},
+			"hmac-sha256:9f62ed92c0d398dede3f0b9f0285c7d89ef7a006a8e82032a179fb73bc9e6812",
+			nil},
+		// 2
+		{Hasher{SHA512_256, nil},
+			"sha512-256:ee30a3dfdcb4ad6546cbbbce99a4f6e42758ffb3781e8a47d2a7ff22f60a4b22",
+			nil},
+		// 3
+		{Hasher{HMACSHA256, nil}, "", ErrKey},
+		// 4
+		{Hasher{SHA256, []byte("key")}, "", ErrKey},
+		// 5
+		{Hasher{0, nil}, "", ErrAlgorithm},
+	} {
+		id, err := e.given.Sum(f)
+		if err != e.err {
+			t.Errorf("%v: %v", i, err)
+		} else if err == nil && id.String() != e.then {
+			t.Errorf("%v: %v", i, id)
+		}
+	}
+}
+
+func TestID_Text(t *testing.T) {
+	id, err := Hasher{SHA512_256, nil}.Sum(buffers.Buffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := json.Marshal(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id2 ID
+	if err := json.Unmarshal(p, &id2); err != nil {
+		t.Error(err)
+	} else if id2 != id {
+		t.Error(id2)
+	}
+	for _, s := range []string{"", "sha256", "sha256:00", "md5:" + id.String()[11:], "sha256:" + id.String()[11:len(id.String())-1] + "x"} {
+		if _, err := ParseID(s); err == nil {
+			t.Error(s)
+		}
+	}
+	if _, err := (ID{}).MarshalText(); err != ErrAlgorithm {
+		t.Error(err)
+	}
+}
+
+func TestID_Binary(t *testing.T) {
+	id, err := Hasher{SHA256, nil}.Sum(buffers.Buffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := id.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(p); l != 33 {
+		t.Error(l)
+	} else if p[0] != 1 {
+		t.Error(p[0])
+	}
+	var id2 ID
+	if err := id2.UnmarshalBinary(p); err != nil {
+		t.Error(err)
+	} else if id2 != id {
+		t.Error(id2)
+	}
+	if err := id2.UnmarshalBinary(p[1:]); err != ErrID {
+		t.Error(err)
+	}
+	p[0] = 0
+	if err := id2.UnmarshalBinary(p); err != ErrAlgorithm {
+		t.Error(err)
+	}
+	if !(ID{}).IsZero() || id.IsZero() {
+		t.Error()
+	}
+}
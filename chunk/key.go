@@ -0,0 +1,88 @@
+package chunk
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+)
+
+// KeySize is the length of the keys made by NewKey.
+const KeySize = 32
+
+// ErrMismatch is returned by Hasher.Verify when the data does not match the
+// ID, and by Hasher.CheckKey when the key does not match the check value.
+var ErrMismatch = errors.New("chunk: mismatch")
+
+// NewKey returns a new random key for a keyed Algorithm. It must be kept by
+// the clients of a Vault and never sent to the Servers, which only store and
+// compare the IDs and therefore deduplicate, copy and collect the chunks the
+// same way whatever the Algorithm is.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ReadKeyFile reads a key written by WriteKeyFile.
+func ReadKeyFile(name string) ([]byte, error) {
+	p, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(p)))
+	if err != nil || len(key) == 0 {
+		return nil, ErrKey
+	}
+	return key, nil
+}
+
+// WriteKeyFile writes the key in hexadecimal to a new file that only its owner
+// can read.
+func WriteKeyFile(name string, key []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var checkLabel = []byte("floc chunk key check")
+
+// Check returns a value that identifies the key of h without disclosing it,
+// so that it can be stored in the Vault and clients can detect that they use
+// a different key, which would silently prevent the deduplication. It is nil
+// for the unkeyed Algorithms.
+func (h Hasher) Check() ([]byte, error) {
+	if _, err := h.New(); err != nil {
+		return nil, err
+	}
+	if !h.Algorithm.Keyed() {
+		return nil, nil
+	}
+	m := hmac.New(sha256.New, h.Key)
+	m.Write(checkLabel)
+	return m.Sum(nil), nil
+}
+
+// CheckKey returns ErrMismatch if check is not the value returned by
+// h.Check().
+func (h Hasher) CheckKey(check []byte) error {
+	c, err := h.Check()
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(c, check) {
+		return ErrMismatch
+	}
+	return nil
+}
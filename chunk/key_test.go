@@ -0,0 +1,101 @@
+package chunk
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+func TestNewKey(t *testing.T) {
+	k1, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k1) != KeySize {
+		t.Error(len(k1))
+	}
+	if bytes.Equal(k1, k2) {
+		t.Error(k1)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "key")
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeyFile(name, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeyFile(name, key); !os.IsExist(err) {
+		t.Error(err)
+	}
+	if fi, err := os.Stat(name); err != nil {
+		t.Error(err)
+	} else if m := fi.Mode().Perm(); m != 0600 {
+		t.Error(m)
+	}
+	if key2, err := ReadKeyFile(name); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(key, key2) {
+		t.Error(key2)
+	}
+	os.WriteFile(name, []byte("xyz\n"), 0600)
+	if _, err := ReadKeyFile(name); err != ErrKey {
+		t.Error(err)
+	}
+}
+
+func TestHasher_Check(t *testing.T) {
+	h1 := Hasher{HMACSHA256, []byte("key1")}
+	h2 := Hasher{HMACSHA256, []byte("key2")}
+	c1, err := h1.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(c1); l != 32 {
+		t.Error(l)
+	}
+	if err := h1.CheckKey(c1); err != nil {
+		t.Error(err)
+	}
+	if err := h2.CheckKey(c1); err != ErrMismatch {
+		t.Error(err)
+	}
+	if c, err := (Hasher{SHA256, nil}).Check(); err != nil || c != nil {
+		t.Error(c, err)
+	}
+	if err := (Hasher{SHA256, nil}).CheckKey(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHasher_Verify(t *testing.T) {
+	f := buffers.Buffers{}.Append([]byte("data"))
+	h1 := Hasher{HMACSHA256, []byte("key1")}
+	h2 := Hasher{HMACSHA256, []byte("key2")}
+	id, err := h1.Sum(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h1.Verify(id, f); err != nil {
+		t.Error(err)
+	}
+	if err := h2.Verify(id, f); err != ErrMismatch {
+		t.Error(err)
+	}
+	if err := h1.Verify(id, buffers.Buffers{}); err != ErrMismatch {
+		t.Error(err)
+	}
+	if err := (Hasher{SHA256, nil}).Verify(id, f); err != ErrMismatch {
+		t.Error(err)
+	}
+}
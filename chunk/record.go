@@ -0,0 +1,222 @@
+package chunk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+// Magic are the first bytes of every encoded Record.
+const Magic = "FLCK"
+
+// Version is the version of the format of the Records that are encoded.
+const Version = 1
+
+// MaxLength is the maximum length of the data and the payload of a Record.
+const MaxLength = 1<<31 - 1
+
+// headerSize is the length of the fixed part of the header: the Magic, the
+// Version, the ID, the Codec, the Cipher, the length of the Envelope, the
+// Parity, the Length and the length of the Payload.
+const headerSize = 4 + 1 + 1 + Size + 1 + 1 + 1 + 3 + 4 + 4
+
+var (
+	// ErrRecord is returned when a Record is malformed.
+	ErrRecord = errors.New("chunk: invalid record")
+
+	// ErrChecksum is returned when the checksum of a Record does not match,
+	// which means that it is corrupted.
+	ErrChecksum = errors.New("chunk: checksum mismatch")
+)
+
+// Cipher is the encryption of the Payload of a Record.
+type Cipher uint8
+
+// The Ciphers.
+const (
+	// Plain is the Cipher of an unencrypted Payload.
+	Plain Cipher = 0
+
+	// AESGCM is AES 256 in GCM mode, whose Envelope is the nonce, as the
+	// crypt package implements it.
+	AESGCM Cipher = 1
+)
+
+// NonceSize is the length of the Envelope of AESGCM.
+const NonceSize = 12
+
+// Valid returns whether c is a known Cipher.
+func (c Cipher) Valid() bool {
+	return c == Plain || c == AESGCM
+}
+
+// Parity tells that the Payload is the shard with index Shard of the
+// Reed-Solomon code of the data in Data data shards and Parity parity shards,
+// as rs.New(Data, Parity) splits it. The zero value means that the Payload is
+// the whole data.
+type Parity struct {
+	Data   uint8
+	Parity uint8
+	Shard  uint8
+}
+
+// Record is a chunk as it is stored and sent: its data after compression
+// with the Codec and then encryption with the Cipher, and the metadata to
+// decode and verify it. It is encoded as the Magic, the Version and the
+// fields in order, with the integers in big endian, followed by the FNV-1a
+// 32 bit hash of all of it.
+type Record struct {
+
+	// ID is the ID of the data, before compression and encryption.
+	ID ID
+
+	Codec  Codec
+	Cipher Cipher
+
+	// Envelope are the parameters of the Cipher, like the nonce. It is empty
+	// for Plain and at most 255 bytes long.
+	Envelope []byte
+
+	Parity Parity
+
+	// Length is the length of the data.
+	Length int
+
+	Payload buffers.Buffers
+}
+
+// Validate returns an error that wraps ErrRecord if the fields of r are not
+// consistent.
+func (r *Record) Validate() error {
+	switch {
+	case !r.ID.Algorithm.Valid():
+		return fmt.Errorf("%w: %v", ErrRecord, ErrAlgorithm)
+	case !r.Codec.Valid():
+		return fmt.Errorf("%w: unknown codec %d", ErrRecord, r.Codec)
+	case !r.Cipher.Valid():
+		return fmt.Errorf("%w: unknown cipher %d", ErrRecord, r.Cipher)
+	case r.Cipher == Plain && len(r.Envelope) > 0, r.Cipher == AESGCM && len(r.Envelope) != NonceSize, len(r.Envelope) > 255:
+		return fmt.Errorf("%w: envelope of %d bytes", ErrRecord, len(r.Envelope))
+	case r.Parity.Data == 0 && r.Parity != Parity{}:
+		return fmt.Errorf("%w: parity without data shards", ErrRecord)
+	case r.Parity.Data > 0 && int(r.Parity.Shard) >= int(r.Parity.Data)+int(r.Parity.Parity):
+		return fmt.Errorf("%w: shard %d out of range", ErrRecord, r.Parity.Shard)
+	case r.Length < 0 || r.Length > MaxLength || r.Payload.N > MaxLength:
+		return fmt.Errorf("%w: length out of range", ErrRecord)
+	case r.Codec == None && r.Cipher == Plain && r.Parity.Data == 0 && r.Payload.N != r.Length:
+		return fmt.Errorf("%w: payload of %d bytes for length %d", ErrRecord, r.Payload.N, r.Length)
+	}
+	return nil
+}
+
+func (r *Record) header() []byte {
+	p := make([]byte, headerSize, headerSize+len(r.Envelope))
+	copy(p, Magic)
+	p[4] = Version
+	p[5] = byte(r.ID.Algorithm)
+	copy(p[6:], r.ID.Sum[:])
+	p[6+Size] = byte(r.Codec)
+	p[7+Size] = byte(r.Cipher)
+	p[8+Size] = byte(len(r.Envelope))
+	p[9+Size], p[10+Size], p[11+Size] = r.Parity.Data, r.Parity.Parity, r.Parity.Shard
+	binary.BigEndian.PutUint32(p[12+Size:], uint32(r.Length))
+	binary.BigEndian.PutUint32(p[16+Size:], uint32(r.Payload.N))
+	return append(p, r.Envelope...)
+}
+
+// WriteTo validates and encodes r to w.
+func (r *Record) WriteTo(w io.Writer) (int64, error) {
+	if err := r.Validate(); err != nil {
+		return 0, err
+	}
+	f := buffers.Buffers{}.Append(r.header()).Append(r.Payload.S...)
+	f = f.Append(f.Hash32(nil))
+	return f.WriteTo(w)
+}
+
+// ReadFrom decodes into r a Record from rd, which may be followed by more
+// data, and validates it. It returns io.EOF if rd is at its end. An error that
+// wraps ErrRecord is returned if it is malformed or truncated, and ErrChecksum
+// if it is corrupted. The Payload is read into segments of
+// buffers.Buffers.ReadFrom and it must be released.
+func (r *Record) ReadFrom(rd io.Reader) (n int64, err error) {
+	p := make([]byte, headerSize, headerSize+255)
+	m, err := io.ReadFull(rd, p)
+	n += int64(m)
+	if err == io.EOF {
+		return n, err
+	} else if err != nil {
+		return n, truncated(err)
+	}
+	if string(p[:4]) != Magic {
+		return n, fmt.Errorf("%w: bad magic", ErrRecord)
+	}
+	if p[4] != Version {
+		return n, fmt.Errorf("%w: unknown version %d", ErrRecord, p[4])
+	}
+	var s Record
+	s.ID.Algorithm = Algorithm(p[5])
+	copy(s.ID.Sum[:], p[6:])
+	s.Codec, s.Cipher = Codec(p[6+Size]), Cipher(p[7+Size])
+	s.Parity = Parity{p[9+Size], p[10+Size], p[11+Size]}
+	s.Length = int(binary.BigEndian.Uint32(p[12+Size:]))
+	size := int64(binary.BigEndian.Uint32(p[16+Size:]))
+	if l := int(p[8+Size]); l > 0 {
+		p = p[:headerSize+l]
+		m, err = io.ReadFull(rd, p[headerSize:])
+		n += int64(m)
+		if err != nil {
+			return n, truncated(err)
+		}
+		s.Envelope = append([]byte(nil), p[headerSize:]...)
+	}
+	if size > MaxLength {
+		return n, fmt.Errorf("%w: length out of range", ErrRecord)
+	}
+	m64, err := s.Payload.ReadFrom(io.LimitReader(rd, size))
+	n += m64
+	if err == nil && m64 < size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		s.Payload.Release()
+		return n, truncated(err)
+	}
+	defer func() {
+		if err != nil {
+			s.Payload.Release()
+		}
+	}()
+	var sum [4]byte
+	m, err = io.ReadFull(rd, sum[:])
+	n += int64(m)
+	if err != nil {
+		return n, truncated(err)
+	}
+	d := fnv.New32a()
+	d.Write(p)
+	if string(s.Payload.Hash(d, nil)) != string(sum[:]) {
+		return n, ErrChecksum
+	}
+	if err = s.Validate(); err != nil {
+		return n, err
+	}
+	*r = s
+	return n, nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %v", ErrRecord, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// Release frees the memory of r.Payload if it was read by ReadFrom.
+func (r *Record) Release() {
+	r.Payload.Release()
+}
@@ -0,0 +1,105 @@
+package chunk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+func testRecord(t *testing.T, data []byte) Record {
+	f := buffers.Buffers{}.Append(data)
+	id, err := (Hasher{Algorithm: SHA256}).Sum(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Record{ID: id, Length: len(data), Payload: f}
+}
+
+func TestRecord(t *testing.T) {
+	r1 := testRecord(t, []byte("hello"))
+	r2 := testRecord(t, bytes.Repeat([]byte("x"), 100000))
+	r2.Payload = r2.Payload.Slice(0, 40000)
+	r2.Parity = Parity{3, 2, 4}
+	var buf bytes.Buffer
+	for _, r := range []Record{r1, r2} {
+		n, err := r.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(headerSize + r.Payload.N + 4); n != want {
+			t.Error(n, want)
+		}
+	}
+	size := buf.Len()
+	for i, want := range []Record{r1, r2} {
+		var r Record
+		if _, err := r.ReadFrom(&buf); err != nil {
+			t.Fatal(i, err)
+		}
+		if r.ID != want.ID || r.Length != want.Length || r.Parity != want.Parity || r.Codec != None || r.Cipher != Plain || r.Envelope != nil {
+			t.Error(i, r)
+		}
+		if !r.Payload.Equal(want.Payload) {
+			t.Error(i, r.Payload.N)
+		}
+		r.Release()
+	}
+	var r Record
+	if n, err := r.ReadFrom(&buf); n != 0 || err != io.EOF {
+		t.Error(n, err, size)
+	}
+}
+
+func TestRecord_Corruption(t *testing.T) {
+	r := testRecord(t, []byte("some data"))
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	p := buf.Bytes()
+	for i := range p {
+		q := append([]byte(nil), p...)
+		q[i] ^= 0x10
+		var s Record
+		if _, err := s.ReadFrom(bytes.NewReader(q)); !errors.Is(err, ErrRecord) && err != ErrChecksum {
+			t.Error(i, err)
+		}
+	}
+	for i := 1; i < len(p); i++ {
+		var s Record
+		if _, err := s.ReadFrom(bytes.NewReader(p[:i])); !errors.Is(err, ErrRecord) {
+			t.Error(i, err)
+		}
+	}
+}
+
+func TestRecord_Validate(t *testing.T) {
+	id := ID{Algorithm: SHA256}
+	five := buffers.Buffers{}.Append([]byte("hello"))
+	for i, r := range []Record{
+		{ID: ID{}},                                       // 0
+		{ID: id, Codec: 255},                             // 1
+		{ID: id, Cipher: 255},                            // 2
+		{ID: id, Envelope: []byte("n")},                  // 3
+		{ID: id, Parity: Parity{0, 1, 0}},                // 4
+		{ID: id, Parity: Parity{2, 1, 3}, Payload: five}, // 5
+		{ID: id, Length: -1},                             // 6
+		{ID: id, Length: 4, Payload: five},               // 7
+		{ID: id, Length: MaxLength + 1},                  // 8
+		{ID: id, Cipher: AESGCM, Envelope: []byte("n")},  // 9
+	} {
+		if err := r.Validate(); !errors.Is(err, ErrRecord) {
+			t.Error(i, err)
+		}
+		if _, err := r.WriteTo(io.Discard); !errors.Is(err, ErrRecord) {
+			t.Error(i, err)
+		}
+	}
+	r := Record{ID: id, Parity: Parity{2, 1, 2}, Length: 10, Payload: five}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+}
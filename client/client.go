@@ -1,26 +1,163 @@
 package client
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net"
 	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/hello"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
 )
 
-// Client dials a connection and invokes a callback to handle it.
+// ErrCallback is returned by Client.Dial when both Serve and Session are nil.
+var ErrCallback = errors.New("client: no Serve or Session")
+
+// Client dials a connection and invokes a callback to handle it. If the
+// callback is nil, the connection is served as JSON RPC v1 with the Methods and
+// the callback Session is invoked instead.
 type Client struct {
 	Network string
 	Address string
 	Timeout time.Duration
 	Serve   func(net.Conn) error
+
+	// Methods are the handlers of the JSON RPC Requests and Notifications.
+	Methods map[string]jrpc.Method
+
+	// Session uses the jrpc.Conn to send Requests and Notifications. The
+	// connection is closed when it returns.
+	Session func(*jrpc.Conn) error
+
+	// PingInterval is passed to jrpc.Conn.
+	PingInterval time.Duration
+
+	// PingTimeout is passed to jrpc.Conn.
+	PingTimeout time.Duration
+
+	// Connect is used instead of net.DialTimeout if it is not nil, like
+	// pipe.DialTimeout.
+	Connect func(network, address string, timeout time.Duration) (net.Conn, error)
+
+	// SessionID, if it is not empty, is sent with hello.Method before
+	// c.Session is called, so that the logs of both sides can be correlated.
+	SessionID string
+
+	// Vault, if it is not empty, is sent with hello.Method after the
+	// SessionID, which must not be empty, and Check is called with its
+	// Metadata.
+	Vault string
+
+	// Check, if it is not nil, is called with the Metadata of the Vault before
+	// c.Session. If it returns an error, the connection is closed and Dial
+	// returns it. Uploaders use it to refuse, or only warn, when their chunker
+	// configuration does not match the Vault with vault.Metadata.Check.
+	Check func(vault.Metadata) error
+
+	// Logger, if it is not nil, is passed to jrpc.Conn with the SessionID.
+	Logger *slog.Logger
+}
+
+// NewSessionID returns a random session id for Client.SessionID.
+func NewSessionID() (string, error) {
+	p := make([]byte, 16)
+	if _, err := rand.Read(p); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(p), nil
 }
 
 // Dial dials a connection to the c.Network and c.Address with the timeout
 // c.Timeout, calls f.Serve, waits for it to finish, closes the connection and
-// returns any error.
+// returns any error. If c.Serve is nil, c.Session is called with a jrpc.Conn
+// that is served meanwhile, after the handshake if c.SessionID is not empty.
+// It returns hello.ErrSession without dialing if c.Vault is set without a
+// c.SessionID, and ErrCallback if both c.Serve and c.Session are nil.
 func (c *Client) Dial() (err error) {
-	conn, err := net.DialTimeout(c.Network, c.Address, c.Timeout)
+	if c.Vault != "" && c.SessionID == "" {
+		return hello.ErrSession
+	}
+	if c.Serve == nil && c.Session == nil {
+		return ErrCallback
+	}
+	connect := c.Connect
+	if connect == nil {
+		connect = net.DialTimeout
+	}
+	conn, err := connect(c.Network, c.Address, c.Timeout)
 	if err != nil {
 		return
 	}
 	defer conn.Close()
+	if c.Serve == nil {
+		return c.serveJRPC(conn)
+	}
 	return c.Serve(conn)
 }
+
+func (c *Client) serveJRPC(conn net.Conn) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jc := &jrpc.Conn{
+		Ctx:          ctx,
+		Conn:         conn,
+		Methods:      c.Methods,
+		PingInterval: c.PingInterval,
+		PingTimeout:  c.PingTimeout,
+	}
+	if c.Logger != nil {
+		jc.Logger = c.Logger.With("session", c.SessionID)
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- jc.Serve()
+	}()
+	err := c.hello(ctx, jc)
+	if err == nil {
+		err = c.Session(jc)
+	}
+	cancel()
+	if serr := <-errs; err == nil && serr != context.Canceled {
+		err = serr
+	}
+	return err
+}
+
+func (c *Client) hello(ctx context.Context, jc *jrpc.Conn) error {
+	if c.SessionID == "" {
+		return nil
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	params := []interface{}{c.SessionID}
+	if c.Vault != "" {
+		params = append(params, c.Vault)
+	}
+	r, err := jc.Call(ctx, hello.Method, params...)
+	if err != nil || c.Vault == "" {
+		return err
+	}
+	p, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	var h hello.Result
+	if err := json.Unmarshal(p, &h); err != nil {
+		return err
+	}
+	if h.Vault == nil {
+		return hello.ErrVault
+	}
+	if c.Check != nil {
+		return c.Check(*h.Vault)
+	}
+	return nil
+}
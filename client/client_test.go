@@ -1,12 +1,24 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/hello"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
 	"github.com/daniel-fanjul-alcuten/floc/listen"
+	"github.com/daniel-fanjul-alcuten/floc/pipe"
+	"github.com/daniel-fanjul-alcuten/floc/server"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
 )
 
 const timeout = 100 * time.Millisecond
@@ -30,8 +42,178 @@ func TestClient(t *testing.T) {
 	serve := func(net.Conn) error {
 		return nil
 	}
-	c := &Client{"tcp", l.Addr().String(), timeout, serve}
+	c := &Client{Network: "tcp", Address: l.Addr().String(), Timeout: timeout, Serve: serve}
 	if err := c.Dial(); err != nil {
 		t.Error(err)
 	}
+	c.Serve = nil
+	if err := c.Dial(); err != ErrCallback {
+		t.Error(err)
+	}
+}
+
+func TestClient_Session(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+	}
+	defer l.Close()
+	ll := listen.Listener{
+		Ctx:      ctx,
+		Listener: l,
+		Timeout:  timeout,
+		Serve: func(conn net.Conn) {
+			c := &jrpc.Conn{Ctx: ctx, Conn: conn, Methods: map[string]jrpc.Method{
+				"slow": func(ctx context.Context, params []interface{}) (interface{}, error) {
+					time.Sleep(3 * timeout)
+					return "done", nil
+				},
+			}}
+			c.Serve()
+		},
+	}
+	go ll.Listen()
+	session := func(c *jrpc.Conn) error {
+		r, err := c.Call(ctx, "slow")
+		if err != nil {
+			return err
+		} else if r != "done" {
+			t.Error(r)
+		}
+		return nil
+	}
+	c := &Client{
+		Network:      "tcp",
+		Address:      l.Addr().String(),
+		Timeout:      timeout,
+		Session:      session,
+		PingInterval: timeout / 2,
+		PingTimeout:  timeout,
+	}
+	if err := c.Dial(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_SessionID(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	s := &server.Server{
+		Ctx:      ctx,
+		Network:  pipe.Network,
+		Address:  "TestClient_SessionID",
+		Timeout:  timeout,
+		Announce: pipe.Listen,
+		Methods: map[string]jrpc.Method{
+			"echo": func(ctx context.Context, params []interface{}) (interface{}, error) {
+				return params[0], nil
+			},
+		},
+	}
+	go s.Listen()
+	buf := &bytes.Buffer{}
+	id, err := NewSessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(id); l != 32 {
+		t.Error(l)
+	}
+	c := &Client{
+		Network: pipe.Network,
+		Address: "TestClient_SessionID",
+		Timeout: timeout,
+		Session: func(c *jrpc.Conn) error {
+			_, err := c.Call(ctx, "echo", "a")
+			return err
+		},
+		Connect:   pipe.DialTimeout,
+		SessionID: id,
+		Logger:    slog.New(slog.NewJSONHandler(buf, nil)),
+	}
+	for i := 0; i == 0 || err == pipe.ErrRefused && i < 10; i++ {
+		if err = c.Dial(); err == pipe.ErrRefused {
+			time.Sleep(timeout / 10)
+		}
+	}
+	if err != nil {
+		t.Error(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal(lines)
+	}
+	for i, line := range lines {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Error(err)
+		} else if m["msg"] != "called" || m["session"] != id || m["id"] != float64(i) || m["outcome"] != "ok" {
+			t.Error(line)
+		}
+	}
+}
+
+func TestClient_Vault(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	h := chunk.Hasher{Algorithm: chunk.SHA256}
+	m, err := vault.NewMetadata("v1", split.Config{Algorithm: split.FastCDC}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server.Server{
+		Ctx:      ctx,
+		Network:  pipe.Network,
+		Address:  "TestClient_Vault",
+		Timeout:  timeout,
+		Announce: pipe.Listen,
+		Vault: func(ctx context.Context, name string) (vault.Metadata, error) {
+			return m, nil
+		},
+	}
+	go s.Listen()
+	for i, c := range []struct {
+		config  split.Config
+		id      string
+		session bool
+		err     error
+	}{
+		{split.Config{Algorithm: split.FastCDC}, "s1", true, nil},                           // 0
+		{split.Config{Algorithm: split.FastCDC, Avg: 4096}, "s1", false, vault.ErrMismatch}, // 1
+		{split.Config{Algorithm: split.FastCDC}, "", false, hello.ErrSession},               // 2
+	} {
+		session := false
+		cl := &Client{
+			Network: pipe.Network,
+			Address: "TestClient_Vault",
+			Timeout: timeout,
+			Session: func(*jrpc.Conn) error {
+				session = true
+				return nil
+			},
+			Connect:   pipe.DialTimeout,
+			SessionID: c.id,
+			Vault:     "v1",
+			Check: func(m vault.Metadata) error {
+				return m.Check(c.config, h)
+			},
+		}
+		var err error
+		for j := 0; j == 0 || err == pipe.ErrRefused && j < 10; j++ {
+			if err = cl.Dial(); err == pipe.ErrRefused {
+				time.Sleep(timeout / 10)
+			}
+		}
+		if !errors.Is(err, c.err) {
+			t.Error(i, err)
+		}
+		if session != c.session {
+			t.Error(i, session)
+		}
+	}
 }
@@ -0,0 +1,87 @@
+// Command floc-chunkstat splits files and directory trees with a chunker
+// configuration and alternatives to it, and reports the number and sizes of
+// the chunks and the deduplication within the input, to choose the
+// configuration of a Vault.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/daniel-fanjul-alcuten/floc/split"
+)
+
+// alts is a flag.Value of the alternative configurations, given as JSON
+// objects with the fields that differ from the base configuration.
+type alts []string
+
+func (a *alts) String() string {
+	return strings.Join(*a, " ")
+}
+
+func (a *alts) Set(s string) error {
+	var c split.Config
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return err
+	}
+	*a = append(*a, s)
+	return nil
+}
+
+func main() {
+	var c split.Config
+	var a alts
+	flag.StringVar(&c.Algorithm, "algorithm", split.Rolling, "algorithm of the chunker: rolling or fastcdc")
+	flag.IntVar(&c.Min, "min", 0, "minimum length of the chunks")
+	flag.IntVar(&c.Max, "max", 0, "maximum length of the chunks")
+	mask := flag.Uint("mask", 0, "mask of the rolling hash")
+	cond := flag.Uint("cond", 0, "condition of the masked rolling hash")
+	flag.IntVar(&c.Window, "window", 0, "window of the rolling hash")
+	flag.IntVar(&c.Avg, "avg", 0, "average length of the fastcdc chunks")
+	flag.IntVar(&c.Level, "level", 0, "normalization level of fastcdc")
+	flag.Var(&a, "alt", "alternative configuration as a JSON object of the fields that differ, like '{\"mask\":8191,\"cond\":8191}' (repeatable)")
+	hashers := flag.Int("hashers", 0, "number of goroutines that hash chunks (default number of CPUs)")
+	readers := flag.Int("readers", 1, "number of files read at the same time")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] path...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	c.Mask, c.Cond = uint32(*mask), uint32(*cond)
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	configs := []split.Config{c}
+	for _, s := range a {
+		alt := c
+		if err := json.Unmarshal([]byte(s), &alt); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		configs = append(configs, alt)
+	}
+	files, err := walk(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for i, c := range configs {
+		if i > 0 {
+			fmt.Println()
+		}
+		r, err := analyze(context.Background(), c, files, *readers, *hashers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := r.write(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/pipeline"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+)
+
+// walk returns the regular files of the paths, descending into directories.
+func walk(paths []string) (files []pipeline.File, err error) {
+	for _, path := range paths {
+		err = filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				files = append(files, pipeline.File{Name: name, Open: func() (io.ReadCloser, error) {
+					return os.Open(name)
+				}})
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// report is the analysis of the chunks of the files with a Config.
+type report struct {
+	Config split.Config
+	Files  int
+	Chunks int
+	Bytes  int64
+	Min    int
+	Max    int
+
+	// Unique and UniqueBytes count the chunks with different IDs.
+	Unique      int
+	UniqueBytes int64
+
+	// Buckets counts the chunks by their lengths: Buckets[i] those of at most
+	// 1<<i bytes and more than 1<<(i-1).
+	Buckets []int
+
+	sum2 float64
+}
+
+func (r *report) add(n int) {
+	if r.Chunks == 0 || n < r.Min {
+		r.Min = n
+	}
+	if n > r.Max {
+		r.Max = n
+	}
+	r.Chunks++
+	r.Bytes += int64(n)
+	r.sum2 += float64(n) * float64(n)
+	i := 0
+	if n > 1 {
+		i = bits.Len(uint(n - 1))
+	}
+	for len(r.Buckets) <= i {
+		r.Buckets = append(r.Buckets, 0)
+	}
+	r.Buckets[i]++
+}
+
+// Avg returns the average length of the chunks.
+func (r *report) Avg() float64 {
+	if r.Chunks == 0 {
+		return 0
+	}
+	return float64(r.Bytes) / float64(r.Chunks)
+}
+
+// StdDev returns the standard deviation of the lengths of the chunks.
+func (r *report) StdDev() float64 {
+	if r.Chunks == 0 {
+		return 0
+	}
+	avg := r.Avg()
+	return math.Sqrt(math.Max(r.sum2/float64(r.Chunks)-avg*avg, 0))
+}
+
+// Ratio returns the ratio of Bytes to UniqueBytes.
+func (r *report) Ratio() float64 {
+	if r.UniqueBytes == 0 {
+		return 1
+	}
+	return float64(r.Bytes) / float64(r.UniqueBytes)
+}
+
+// analyze splits the files with c and returns their report.
+func analyze(ctx context.Context, c split.Config, files []pipeline.File, readers, hashers int) (*report, error) {
+	r := &report{Config: c}
+	var mu sync.Mutex
+	seen := make(map[chunk.ID]struct{})
+	p := &pipeline.Pipeline{
+		Config:  c,
+		Readers: readers,
+		Hashers: hashers,
+		Lookup: func(ctx context.Context, id chunk.ID) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			_, ok := seen[id]
+			seen[id] = struct{}{}
+			return ok, nil
+		},
+	}
+	err := p.Run(ctx, files, func(res pipeline.Result) error {
+		if res.Err != nil {
+			res.Chunk.Release()
+			return fmt.Errorf("%v: %w", res.File.Name, res.Err)
+		}
+		if res.EOF {
+			r.Files++
+			return nil
+		}
+		n := res.Chunk.Data.N
+		res.Chunk.Release()
+		r.add(n)
+		if !res.Known {
+			r.Unique++
+			r.UniqueBytes += int64(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// write writes r in a human readable format.
+func (r *report) write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	config, _ := json.Marshal(r.Config)
+	fmt.Fprintf(b, "config  %s\n", config)
+	if f, err := r.Config.Fingerprint(); err == nil {
+		fmt.Fprintf(b, "fingerprint  %s\n", f)
+	}
+	fmt.Fprintf(b, "files  %d\n", r.Files)
+	fmt.Fprintf(b, "bytes  %d\n", r.Bytes)
+	fmt.Fprintf(b, "chunks  %d\n", r.Chunks)
+	fmt.Fprintf(b, "length  min %d  avg %.0f  max %d  stddev %.0f\n", r.Min, r.Avg(), r.Max, r.StdDev())
+	fmt.Fprintf(b, "unique  %d chunks  %d bytes\n", r.Unique, r.UniqueBytes)
+	fmt.Fprintf(b, "dedup ratio  %.3f\n", r.Ratio())
+	for i, n := range r.Buckets {
+		if n == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "<= %d  %d  %.1f%%\n", 1<<i, n, 100*float64(n)/float64(r.Chunks))
+	}
+	return b.Flush()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/split"
+)
+
+func TestAnalyze(t *testing.T) {
+	dir := t.TempDir()
+	p := make([]byte, 1<<18)
+	rand.New(rand.NewSource(1)).Read(p)
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"f1", "a/f2", "a/b/f3"} {
+		if err := os.WriteFile(filepath.Join(dir, name), p, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := walk([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatal(files)
+	}
+	for i, c := range []split.Config{
+		{Algorithm: split.Rolling, Mask: 1<<12 - 1, Cond: 1<<12 - 1, Window: 64}, // 0
+		{Algorithm: split.FastCDC, Avg: 1 << 12},                                 // 1
+	} {
+		r, err := analyze(context.Background(), c, files, 2, 2)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if r.Files != 3 || r.Bytes != 3<<18 || r.Unique*3 != r.Chunks || r.UniqueBytes != 1<<18 {
+			t.Error(i, r.Files, r.Bytes, r.Chunks, r.Unique, r.UniqueBytes)
+		}
+		if x := r.Ratio(); x != 3 {
+			t.Error(i, x)
+		}
+		n := 0
+		for _, b := range r.Buckets {
+			n += b
+		}
+		if n != r.Chunks || r.Min > r.Max || r.Chunks < 16 {
+			t.Error(i, r.Buckets, r.Min, r.Max)
+		}
+		buf := &bytes.Buffer{}
+		if err := r.write(buf); err != nil {
+			t.Error(err)
+		}
+		if s := buf.String(); !strings.Contains(s, "dedup ratio  3.000\n") {
+			t.Error(i, s)
+		}
+	}
+	if _, err := analyze(context.Background(), split.Config{}, files, 1, 1); err != split.ErrAlgorithm {
+		t.Error(err)
+	}
+	os.Remove(filepath.Join(dir, "f1"))
+	if _, err := analyze(context.Background(), split.Config{Algorithm: split.FastCDC}, files, 1, 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Error(err)
+	}
+}
+
+func TestReport_Buckets(t *testing.T) {
+	r := &report{}
+	for _, n := range []int{1, 2, 3, 4, 5, 8, 9} {
+		r.add(n)
+	}
+	if s := fmt.Sprint(r.Buckets); s != "[1 1 2 2 1]" {
+		t.Error(s)
+	}
+	if r.Min != 1 || r.Max != 9 || r.Bytes != 32 {
+		t.Error(r.Min, r.Max, r.Bytes)
+	}
+}
@@ -0,0 +1,78 @@
+// Command floc-fs is a Server that keeps the Vaults, the Archives and the
+// chunks in a directory tree of plain files, which can be inspected with
+// standard tools and copied offsite with rsync while the Server is stopped.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/backend/fs"
+	"github.com/daniel-fanjul-alcuten/floc/server"
+	"github.com/daniel-fanjul-alcuten/floc/stats"
+)
+
+type options struct {
+	network string
+	address string
+	timeout time.Duration
+	dir     string
+	stats   string
+	verbose bool
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.network, "network", "unix", "network of the address: unix or tcp")
+	flag.StringVar(&o.address, "address", "floc-fs.socket", "address to listen on")
+	flag.DurationVar(&o.timeout, "timeout", time.Second, "interval to check for the termination")
+	flag.StringVar(&o.dir, "dir", "floc-fs", "directory of the storage, created if it does not exist")
+	flag.StringVar(&o.stats, "stats", "", "loopback TCP address to serve the stats over HTTP")
+	flag.BoolVar(&o.verbose, "verbose", false, "log the connections and the requests to stderr")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, o); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run serves the fs.Backend in o.dir until ctx is done.
+func run(ctx context.Context, o options) error {
+	b, err := fs.Open(o.dir)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	st := &stats.Stats{}
+	s := &backend.Service{Backend: b, Stats: st}
+	srv := &server.Server{
+		Ctx:          ctx,
+		Network:      o.network,
+		Address:      o.address,
+		Timeout:      o.timeout,
+		Methods:      s.Methods(),
+		Stats:        st,
+		StatsAddress: o.stats,
+		Vault:        s.Vault,
+	}
+	if o.verbose {
+		srv.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	if err := srv.Listen(); err != context.Canceled {
+		return err
+	}
+	return nil
+}
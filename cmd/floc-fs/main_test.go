@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend/backendtest"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	o := options{
+		network: "unix",
+		address: filepath.Join(dir, "socket"),
+		timeout: 10 * time.Millisecond,
+		dir:     filepath.Join(dir, "storage"),
+	}
+	backendtest.Server(t, o.network, o.address, func(ctx context.Context) error {
+		return run(ctx, o)
+	})
+}
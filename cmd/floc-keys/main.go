@@ -0,0 +1,317 @@
+// Command floc-keys manages the Keyring of the master key of an encrypted
+// Vault: it creates the master key, adds and removes passphrases and key
+// files, rotates their secrets and exports recovery keys.
+// The Keyring file is encrypted, and push and pull store it with the metadata
+// of the Vault in a Server.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/client"
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+const usage = `usage: %s [flags] command
+
+commands:
+  init      creates the master key of -vault with the secret -pass or -key
+  add       adds the secret -new-pass or -new-key in the slot -name
+  remove    removes the slot -name
+  rotate    replaces the secret of the slot that -pass or -key unlocks with
+            -new-pass or -new-key and keeps the other slots
+  recovery  adds a new random recovery key in the slot -name and prints it
+            in the format of a key file
+  list      lists the slots
+  push      stores the keyring in the metadata of its vault in -server
+  pull      replaces the keyring with the one of -vault in -server
+
+flags:
+`
+
+type options struct {
+	keyring string
+	vault   string
+	name    string
+	pass    string
+	key     string
+	newPass string
+	newKey  string
+	network string
+	server  string
+	timeout time.Duration
+
+	// connect is passed to client.Client.Connect.
+	connect func(network, address string, timeout time.Duration) (net.Conn, error)
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.keyring, "keyring", "floc.keyring", "file of the keyring")
+	flag.StringVar(&o.vault, "vault", "", "name of the vault for init and pull")
+	flag.StringVar(&o.name, "name", "default", "name of the slot")
+	flag.StringVar(&o.pass, "pass", "", "file with the passphrase that unlocks the keyring, - for stdin")
+	flag.StringVar(&o.key, "key", "", "key file that unlocks the keyring")
+	flag.StringVar(&o.newPass, "new-pass", "", "file with the passphrase to add or rotate to, - for stdin")
+	flag.StringVar(&o.newKey, "new-key", "", "key file to add or rotate to, created if it does not exist")
+	flag.StringVar(&o.network, "network", "tcp", "network of the server for push and pull")
+	flag.StringVar(&o.server, "server", "", "address of the server for push and pull")
+	flag.DurationVar(&o.timeout, "timeout", 10*time.Second, "timeout of the connection to the server")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), o, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned by run for missing or unknown flags and commands.
+var errUsage = errors.New("floc-keys: invalid usage, see -help")
+
+func readPassphrase(name string, stdin *bufio.Reader) ([]byte, error) {
+	if name == "-" {
+		p, err := stdin.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return bytes.TrimRight(p, "\r\n"), nil
+	}
+	p, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(p, "\r\n"), nil
+}
+
+// secret returns the passphrase or the key of the files pass or key.
+func secret(pass, key string, stdin *bufio.Reader) ([]byte, bool, error) {
+	switch {
+	case pass != "" && key == "":
+		p, err := readPassphrase(pass, stdin)
+		return p, true, err
+	case key != "" && pass == "":
+		k, err := chunk.ReadKeyFile(key)
+		return k, false, err
+	}
+	return nil, false, errUsage
+}
+
+// newSecret returns the passphrase or the key of the files newPass or newKey,
+// creating the key file if it does not exist.
+func newSecret(newPass, newKey string, stdin *bufio.Reader) ([]byte, bool, error) {
+	switch {
+	case newPass != "" && newKey == "":
+		p, err := readPassphrase(newPass, stdin)
+		return p, true, err
+	case newKey != "" && newPass == "":
+		key, err := chunk.ReadKeyFile(newKey)
+		if os.IsNotExist(err) {
+			if key, err = chunk.NewKey(); err == nil {
+				err = chunk.WriteKeyFile(newKey, key)
+			}
+		}
+		return key, false, err
+	}
+	return nil, false, errUsage
+}
+
+func load(name string) (*crypt.Keyring, error) {
+	p, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	k := &crypt.Keyring{}
+	if err := json.Unmarshal(p, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// save writes k to a temporary file that replaces name, so that the keyring
+// is never lost halfway.
+func save(name string, k *crypt.Keyring) error {
+	p, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(p, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// session calls f with a vault.Client of o.server.
+func session(o options, f func(ctx context.Context, c *vault.Client) error) error {
+	if o.server == "" {
+		return errUsage
+	}
+	id, err := client.NewSessionID()
+	if err != nil {
+		return err
+	}
+	c := &client.Client{
+		Network:   o.network,
+		Address:   o.server,
+		Timeout:   o.timeout,
+		Connect:   o.connect,
+		SessionID: id,
+		Session: func(conn *jrpc.Conn) error {
+			ctx := context.Background()
+			if o.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, o.timeout)
+				defer cancel()
+			}
+			return f(ctx, &vault.Client{Conn: conn})
+		},
+	}
+	return c.Dial()
+}
+
+func run(cmd string, o options, r io.Reader, stdout io.Writer) error {
+	stdin := bufio.NewReader(r)
+	if cmd == "pull" {
+		if o.vault == "" {
+			return errUsage
+		}
+		return session(o, func(ctx context.Context, c *vault.Client) error {
+			v, err := c.GetVault(ctx, o.vault)
+			if err != nil {
+				return err
+			}
+			if v.Keyring == nil {
+				return fmt.Errorf("floc-keys: vault %q has no keyring", o.vault)
+			}
+			return save(o.keyring, v.Keyring)
+		})
+	}
+	if cmd == "init" {
+		if o.vault == "" {
+			return errUsage
+		}
+		if _, err := os.Stat(o.keyring); err == nil {
+			return fmt.Errorf("floc-keys: %v already exists", o.keyring)
+		}
+		s, pass, err := secret(o.pass, o.key, stdin)
+		if err != nil {
+			return err
+		}
+		master, err := crypt.NewMasterKey()
+		if err != nil {
+			return err
+		}
+		k := &crypt.Keyring{Vault: o.vault}
+		if pass {
+			err = k.AddPassphrase(master, o.name, s)
+		} else {
+			err = k.AddKey(master, o.name, s)
+		}
+		if err != nil {
+			return err
+		}
+		return save(o.keyring, k)
+	}
+	k, err := load(o.keyring)
+	if err != nil {
+		return err
+	}
+	if cmd == "list" {
+		for _, s := range k.Slots {
+			fmt.Fprintf(stdout, "%s\t%s\n", s.Name, s.KDF)
+		}
+		return nil
+	}
+	if cmd == "push" {
+		return session(o, func(ctx context.Context, c *vault.Client) error {
+			_, err := c.SetKeyring(ctx, k.Vault, k)
+			return err
+		})
+	}
+	s, _, err := secret(o.pass, o.key, stdin)
+	if err != nil {
+		return err
+	}
+	master, _, err := k.Unlock(s)
+	if err != nil {
+		return err
+	}
+	switch cmd {
+	case "add":
+		p, pass, err := newSecret(o.newPass, o.newKey, stdin)
+		if err != nil {
+			return err
+		}
+		if pass {
+			err = k.AddPassphrase(master, o.name, p)
+		} else {
+			err = k.AddKey(master, o.name, p)
+		}
+		if err != nil {
+			return err
+		}
+	case "remove":
+		if err := k.Remove(o.name); err != nil {
+			return err
+		}
+	case "rotate":
+		p, pass, err := newSecret(o.newPass, o.newKey, stdin)
+		if err != nil {
+			return err
+		}
+		if pass {
+			_, err = k.RotatePassphrase(s, p)
+		} else {
+			_, err = k.RotateKey(s, p)
+		}
+		if err != nil {
+			return err
+		}
+	case "recovery":
+		key, err := chunk.NewKey()
+		if err != nil {
+			return err
+		}
+		if err := k.AddKey(master, o.name, key); err != nil {
+			return err
+		}
+		if err := save(o.keyring, k); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, hex.EncodeToString(key))
+		return err
+	default:
+		return errUsage
+	}
+	return save(o.keyring, k)
+}
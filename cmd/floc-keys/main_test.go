@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/backend/mem"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+	"github.com/daniel-fanjul-alcuten/floc/pipe"
+	"github.com/daniel-fanjul-alcuten/floc/server"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	keyring := filepath.Join(dir, "keyring")
+	keyFile := filepath.Join(dir, "key")
+	recovery := filepath.Join(dir, "recovery")
+	unlock := func(pass, key string) []byte {
+		k, err := load(keyring)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _, err := secret(pass, key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, _, err := k.Unlock(s)
+		if err != nil {
+			return nil
+		}
+		return m
+	}
+	passFile := filepath.Join(dir, "pass")
+	if err := os.WriteFile(passFile, []byte("pass 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o := options{keyring: keyring, vault: "v1", name: "alice", pass: "-"}
+	if err := run("init", o, strings.NewReader("pass 1\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("init", o, strings.NewReader("pass 1\n"), nil); err == nil {
+		t.Error(err)
+	}
+	master := unlock(passFile, "")
+	if master == nil {
+		t.Fatal()
+	}
+	o = options{keyring: keyring, name: "bob", pass: "-", newPass: "-"}
+	if err := run("add", o, strings.NewReader("pass 1\npass 2\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	o = options{keyring: keyring, name: "laptop", pass: passFile, newKey: keyFile}
+	if err := run("add", o, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if m := unlock("", keyFile); !bytes.Equal(m, master) {
+		t.Error(m)
+	}
+	o = options{keyring: keyring, name: "recovery", key: keyFile}
+	out := &bytes.Buffer{}
+	if err := run("recovery", o, nil, out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hex.DecodeString(strings.TrimSpace(out.String())); err != nil {
+		t.Error(out)
+	}
+	if err := os.WriteFile(recovery, out.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if m := unlock("", recovery); !bytes.Equal(m, master) {
+		t.Error(m)
+	}
+	out.Reset()
+	if err := run("list", options{keyring: keyring}, nil, out); err != nil {
+		t.Error(err)
+	}
+	if s := out.String(); s != "alice\tscrypt\nbob\tscrypt\nlaptop\traw\nrecovery\traw\n" {
+		t.Error(s)
+	}
+	o = options{keyring: keyring, name: "bob", pass: passFile}
+	if err := run("remove", o, nil, nil); err != nil {
+		t.Error(err)
+	}
+	o = options{keyring: keyring, key: recovery}
+	if err := run("rotate", o, nil, nil); err != errUsage {
+		t.Error(err)
+	}
+	recovery2 := filepath.Join(dir, "recovery2")
+	o = options{keyring: keyring, key: recovery, newKey: recovery2}
+	if err := run("rotate", o, nil, nil); err != nil {
+		t.Error(err)
+	}
+	if m := unlock("", recovery); m != nil {
+		t.Error(m)
+	}
+	if m := unlock("", recovery2); !bytes.Equal(m, master) {
+		t.Error(m)
+	}
+	o = options{keyring: keyring, pass: passFile, newPass: "-"}
+	if err := run("rotate", o, strings.NewReader("pass 3\n"), nil); err != nil {
+		t.Error(err)
+	}
+	if m := unlock(passFile, ""); m != nil {
+		t.Error(m)
+	}
+	if m := unlock("", recovery2); !bytes.Equal(m, master) {
+		t.Error(m)
+	}
+	out.Reset()
+	if err := run("list", options{keyring: keyring}, nil, out); err != nil {
+		t.Error(err)
+	}
+	if s := out.String(); s != "alice\tscrypt\nlaptop\traw\nrecovery\traw\n" {
+		t.Error(s)
+	}
+	o = options{keyring: keyring, pass: "-", newPass: "-"}
+	if err := run("rotate", o, strings.NewReader("pass 1\npass 4\n"), nil); err != crypt.ErrLocked {
+		t.Error(err)
+	}
+	if err := run("other", options{keyring: keyring, key: recovery2}, nil, nil); err != errUsage {
+		t.Error(err)
+	}
+	if err := run("add", options{keyring: keyring, key: recovery2}, nil, nil); err != errUsage {
+		t.Error(err)
+	}
+}
+
+func TestRun_Server(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &backend.Service{Backend: &mem.Backend{}}
+	srv := &server.Server{
+		Ctx:      ctx,
+		Network:  pipe.Network,
+		Address:  "TestRun_Server",
+		Timeout:  time.Second,
+		Methods:  s.Methods(),
+		Announce: pipe.Listen,
+	}
+	go srv.Listen()
+	m, err := vault.NewMetadata("v1", split.Config{Algorithm: split.FastCDC}, chunk.Hasher{Algorithm: chunk.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateVault(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyring := filepath.Join(dir, "keyring")
+	o := options{keyring: keyring, vault: "v1", name: "alice", pass: "-"}
+	if err := run("init", o, strings.NewReader("pass 1\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	o = options{keyring: keyring, network: pipe.Network, server: "TestRun_Server", timeout: time.Second, connect: pipe.DialTimeout}
+	for i := 0; ; i++ {
+		err := run("push", o, nil, nil)
+		if err == nil {
+			break
+		}
+		if err != pipe.ErrRefused || i == 10 {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Second / 10)
+	}
+	if v, err := s.GetVault(ctx, "v1"); err != nil || v.Keyring == nil || v.Keyring.Slots[0].Name != "alice" {
+		t.Error(v, err)
+	}
+	o.keyring, o.vault = filepath.Join(dir, "pulled"), "v1"
+	if err := run("pull", o, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	k, err := load(o.keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, name, err := k.Unlock([]byte("pass 1")); err != nil || name != "alice" {
+		t.Error(name, err)
+	}
+	o.vault = "v2"
+	if err := run("pull", o, nil, nil); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	o.server = ""
+	if err := run("push", o, nil, nil); err != errUsage {
+		t.Error(err)
+	}
+}
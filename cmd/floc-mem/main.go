@@ -0,0 +1,88 @@
+// Command floc-mem is a Server that keeps the Vaults, the Archives and the
+// chunks in memory. It is meant for tests and for staging copies between
+// Servers, and it may save its data to a snapshot file on exit and load it
+// again on start.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/backend/mem"
+	"github.com/daniel-fanjul-alcuten/floc/server"
+	"github.com/daniel-fanjul-alcuten/floc/stats"
+)
+
+type options struct {
+	network  string
+	address  string
+	timeout  time.Duration
+	snapshot string
+	stats    string
+	verbose  bool
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.network, "network", "unix", "network of the address: unix or tcp")
+	flag.StringVar(&o.address, "address", "floc-mem.socket", "address to listen on")
+	flag.DurationVar(&o.timeout, "timeout", time.Second, "interval to check for the termination")
+	flag.StringVar(&o.snapshot, "snapshot", "", "file loaded on start, if it exists, and saved on exit")
+	flag.StringVar(&o.stats, "stats", "", "loopback TCP address to serve the stats over HTTP")
+	flag.BoolVar(&o.verbose, "verbose", false, "log the connections and the requests to stderr")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, o); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run serves a new mem.Backend until ctx is done.
+func run(ctx context.Context, o options) error {
+	b := &mem.Backend{}
+	if o.snapshot != "" {
+		if err := b.Load(o.snapshot); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	st := &stats.Stats{}
+	s := &backend.Service{Backend: b, Stats: st}
+	srv := &server.Server{
+		Ctx:          ctx,
+		Network:      o.network,
+		Address:      o.address,
+		Timeout:      o.timeout,
+		Methods:      s.Methods(),
+		Stats:        st,
+		StatsAddress: o.stats,
+		Vault:        s.Vault,
+	}
+	if o.verbose {
+		srv.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	err := srv.Listen()
+	if err == context.Canceled {
+		err = nil
+	}
+	b.Close()
+	if o.snapshot != "" {
+		if serr := b.Save(o.snapshot); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
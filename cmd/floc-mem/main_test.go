@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend/backendtest"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	o := options{
+		network:  "unix",
+		address:  filepath.Join(dir, "socket"),
+		timeout:  10 * time.Millisecond,
+		snapshot: filepath.Join(dir, "snapshot"),
+	}
+	backendtest.Server(t, o.network, o.address, func(ctx context.Context) error {
+		return run(ctx, o)
+	})
+}
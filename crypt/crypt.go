@@ -0,0 +1,189 @@
+// Package crypt encrypts in the client the chunks and the metadata of a
+// Vault, so that the Servers and their operators cannot read them.
+//
+// Each Vault has a random master key from which its Keys are derived with
+// HKDF SHA 256: the key of the HMAC SHA 256 chunk IDs, which keeps the
+// deduplication within the Vault, the key of the chunk Payloads and the key
+// of the metadata, like Catalogs and Archives. All encryption is AES 256 GCM
+// with random nonces.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+// KeySize is the length of the master keys and of the derived keys.
+const KeySize = 32
+
+// version is the first byte of the sealed metadata.
+const version = 1
+
+var (
+	// ErrKey is returned for a key of a wrong length.
+	ErrKey = errors.New("crypt: invalid key")
+
+	// ErrDecrypt is returned when the data cannot be decrypted because the
+	// key is wrong or the data was modified.
+	ErrDecrypt = errors.New("crypt: message authentication failed")
+)
+
+// NewMasterKey returns a new random master key.
+func NewMasterKey() ([]byte, error) {
+	k := make([]byte, KeySize)
+	if _, err := rand.Read(k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Keys are the keys of a Vault derived from its master key.
+type Keys struct {
+
+	// ID is the key of the chunk.HMACSHA256 IDs.
+	ID []byte
+
+	// Data is the key of the Payloads of the chunk.Records.
+	Data []byte
+
+	// Meta is the key of the metadata.
+	Meta []byte
+}
+
+// Derive returns the Keys of the Vault with the name and the master key.
+func Derive(master []byte, vault string) (k Keys, err error) {
+	if len(master) != KeySize {
+		return k, ErrKey
+	}
+	for _, d := range []struct {
+		key  *[]byte
+		info string
+	}{
+		{&k.ID, "floc chunk id"},
+		{&k.Data, "floc chunk data"},
+		{&k.Meta, "floc metadata"},
+	} {
+		if *d.key, err = hkdf.Key(sha256.New, master, []byte(vault), d.info, KeySize); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Hasher returns the chunk.Hasher of the IDs of the Vault.
+func (k Keys) Hasher() chunk.Hasher {
+	return chunk.Hasher{Algorithm: chunk.HMACSHA256, Key: k.ID}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrKey
+	}
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+// recordData binds the Payload of r to its ID, Codec and Length, so that it
+// cannot be swapped with another one.
+func recordData(r chunk.Record) []byte {
+	id, _ := r.ID.MarshalBinary()
+	ad := append(id, byte(r.Codec))
+	return binary.BigEndian.AppendUint32(ad, uint32(r.Length))
+}
+
+// SealRecord returns r with its Payload encrypted with k.Data. r must be plain
+// and must not be a Reed-Solomon shard. The Payload of r is not modified.
+func (k Keys) SealRecord(r chunk.Record) (chunk.Record, error) {
+	if r.Cipher != chunk.Plain || r.Parity.Data > 0 {
+		return r, fmt.Errorf("%w: not the whole plain data", chunk.ErrRecord)
+	}
+	a, err := newAEAD(k.Data)
+	if err != nil {
+		return r, err
+	}
+	nonce := make([]byte, chunk.NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return r, err
+	}
+	p := make([]byte, 0, r.Payload.N+a.Overhead())
+	for _, q := range r.Payload.S {
+		p = append(p, q...)
+	}
+	p = a.Seal(p[:0], nonce, p, recordData(r))
+	r.Cipher, r.Envelope, r.Payload = chunk.AESGCM, nonce, buffers.Buffers{}.Append(p)
+	return r, nil
+}
+
+// OpenRecord returns r with its Payload decrypted with k.Data, or ErrDecrypt.
+// The Payload of r is not modified.
+func (k Keys) OpenRecord(r chunk.Record) (chunk.Record, error) {
+	if r.Cipher != chunk.AESGCM || len(r.Envelope) != chunk.NonceSize {
+		return r, fmt.Errorf("%w: not encrypted", chunk.ErrRecord)
+	}
+	a, err := newAEAD(k.Data)
+	if err != nil {
+		return r, err
+	}
+	p, err := a.Open(nil, r.Envelope, r.Payload.Bytes(), recordData(r))
+	if err != nil {
+		return r, ErrDecrypt
+	}
+	r.Cipher, r.Envelope, r.Payload = chunk.Plain, nil, buffers.Buffers{}.Append(p)
+	return r, nil
+}
+
+// Seal encrypts p with key and authenticates it with the additional data ad,
+// which is not included. The result is a version byte, the nonce and the
+// ciphertext.
+func Seal(key, p, ad []byte) ([]byte, error) {
+	a, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	q := make([]byte, 1+a.NonceSize(), 1+a.NonceSize()+len(p)+a.Overhead())
+	q[0] = version
+	if _, err := rand.Read(q[1:]); err != nil {
+		return nil, err
+	}
+	return a.Seal(q, q[1:], p, ad), nil
+}
+
+// Open decrypts p, sealed by Seal with the key and the additional data ad, or
+// returns ErrDecrypt.
+func Open(key, p, ad []byte) ([]byte, error) {
+	a, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(p) < 1+a.NonceSize()+a.Overhead() || p[0] != version {
+		return nil, ErrDecrypt
+	}
+	q, err := a.Open(nil, p[1:1+a.NonceSize()], p[1+a.NonceSize():], ad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return q, nil
+}
+
+// SealMeta encrypts the metadata p, like a Catalog or an Archive, with
+// k.Meta. ad, like the name of the Archive, must be given again to OpenMeta.
+func (k Keys) SealMeta(p, ad []byte) ([]byte, error) {
+	return Seal(k.Meta, p, ad)
+}
+
+// OpenMeta decrypts the metadata p sealed by SealMeta.
+func (k Keys) OpenMeta(p, ad []byte) ([]byte, error) {
+	return Open(k.Meta, p, ad)
+}
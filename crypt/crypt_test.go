@@ -0,0 +1,116 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+func testKeys(t *testing.T, vault string) Keys {
+	k, err := Derive(bytes.Repeat([]byte{7}, KeySize), vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestDerive(t *testing.T) {
+	k1, k2 := testKeys(t, "v1"), testKeys(t, "v2")
+	for i, p := range [][]byte{k1.ID, k1.Data, k1.Meta, k2.ID} {
+		if len(p) != KeySize {
+			t.Error(i, len(p))
+		}
+	}
+	if bytes.Equal(k1.ID, k1.Data) || bytes.Equal(k1.Data, k1.Meta) || bytes.Equal(k1.ID, k2.ID) {
+		t.Error(k1, k2)
+	}
+	if k := testKeys(t, "v1"); !bytes.Equal(k.ID, k1.ID) {
+		t.Error(k)
+	}
+	if _, err := Derive([]byte("short"), "v1"); err != ErrKey {
+		t.Error(err)
+	}
+	m, err := NewMasterKey()
+	if err != nil || len(m) != KeySize {
+		t.Error(m, err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	k := testKeys(t, "v1")
+	data := buffers.Buffers{}.Append(bytes.Repeat([]byte("secret "), 100))
+	r, err := chunk.NewRecord(k.Hasher(), data, chunk.Flate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := k.SealRecord(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Cipher != chunk.AESGCM || bytes.Contains(s.Payload.Bytes(), r.Payload.Bytes()) {
+		t.Error(s.Cipher)
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secret")) {
+		t.Error(buf.String())
+	}
+	var s2 chunk.Record
+	if _, err := s2.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Release()
+	o, err := k.OpenRecord(s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, err := o.Data(k.Hasher()); err != nil || !f.Equal(data) {
+		t.Error(err)
+	} else {
+		f.Release()
+	}
+	if _, err := testKeys(t, "v2").OpenRecord(s2); err != ErrDecrypt {
+		t.Error(err)
+	}
+	s2.ID.Sum[0]++
+	if _, err := k.OpenRecord(s2); err != ErrDecrypt {
+		t.Error(err)
+	}
+	if _, err := k.OpenRecord(r); !errors.Is(err, chunk.ErrRecord) {
+		t.Error(err)
+	}
+	if _, err := k.SealRecord(s); !errors.Is(err, chunk.ErrRecord) {
+		t.Error(err)
+	}
+}
+
+func TestMeta(t *testing.T) {
+	k := testKeys(t, "v1")
+	p, err := k.SealMeta([]byte("catalog"), []byte("archive 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, err := k.OpenMeta(p, []byte("archive 1")); err != nil || string(q) != "catalog" {
+		t.Error(q, err)
+	}
+	for i, c := range []struct {
+		p, ad []byte
+	}{
+		{p, []byte("archive 2")},                           // 0
+		{p[:len(p)-1], []byte("archive 1")},                // 1
+		{p[:5], []byte("archive 1")},                       // 2
+		{append([]byte{2}, p[1:]...), []byte("archive 1")}, // 3
+	} {
+		if _, err := k.OpenMeta(c.p, c.ad); err != ErrDecrypt {
+			t.Error(i, err)
+		}
+	}
+	if _, err := Seal([]byte("short"), nil, nil); err != ErrKey {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,236 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// The KDFs of the Slots.
+const (
+	// Scrypt derives the key of a Slot from a passphrase.
+	Scrypt = "scrypt"
+
+	// Raw uses a random key, like a key file or a recovery key, as the key of
+	// a Slot.
+	Raw = "raw"
+)
+
+// scryptN is the cost of the new Scrypt Slots.
+var scryptN = 1 << 15
+
+// The limits of the parameters of the Scrypt Slots, which may come from an
+// untrusted Keyring, so that Unlock takes bounded CPU and at most 256 MiB.
+const (
+	maxScryptN = 1 << 18
+	maxScryptR = 8
+	maxScryptP = 4
+
+	// maxScryptSlots is the number of Scrypt Slots that Unlock tries.
+	maxScryptSlots = 8
+)
+
+var (
+	// ErrLocked is returned when no Slot of a Keyring is unlocked by a secret.
+	ErrLocked = errors.New("crypt: no slot unlocks the keyring")
+
+	// ErrSlot is returned when a Slot is added with a name in use or removed
+	// with a name not in use.
+	ErrSlot = errors.New("crypt: invalid slot name")
+
+	// ErrLastSlot is returned when the last Slot would be removed, which would
+	// lose the master key.
+	ErrLastSlot = errors.New("crypt: cannot remove the last slot")
+
+	// ErrKDF is returned for a Slot with an unknown KDF or with Scrypt
+	// parameters out of their limits.
+	ErrKDF = errors.New("crypt: invalid kdf parameters")
+
+	// ErrSlots is returned when a Scrypt Slot is added to a Keyring that has
+	// as many as Unlock tries.
+	ErrSlots = errors.New("crypt: too many passphrase slots")
+
+	// ErrRotate is returned when a Slot is rotated to the same secret, which
+	// would revoke nothing.
+	ErrRotate = errors.New("crypt: the new secret is the old one")
+)
+
+// Slot is the master key of a Vault encrypted with a key derived by its KDF
+// from a secret, a passphrase or a random key.
+type Slot struct {
+	Name string `json:"name"`
+	KDF  string `json:"kdf"`
+
+	// Salt, N, R and P are the parameters of Scrypt.
+	Salt []byte `json:"salt,omitempty"`
+	N    int    `json:"n,omitempty"`
+	R    int    `json:"r,omitempty"`
+	P    int    `json:"p,omitempty"`
+
+	// Key is the master key sealed with the key of the Slot and the name of
+	// the Vault and of the Slot as additional data.
+	Key []byte `json:"key"`
+}
+
+func (s *Slot) key(secret []byte) ([]byte, error) {
+	switch s.KDF {
+	case Scrypt:
+		if s.N > maxScryptN || s.R < 1 || s.R > maxScryptR || s.P < 1 || s.P > maxScryptP {
+			return nil, ErrKDF
+		}
+		return scrypt.Key(secret, s.Salt, s.N, s.R, s.P, KeySize)
+	case Raw:
+	default:
+		return nil, ErrKDF
+	}
+	if len(secret) != KeySize {
+		return nil, ErrKey
+	}
+	return secret, nil
+}
+
+// Keyring keeps the master key of a Vault in several Slots, so that any of
+// their secrets unlocks it. It is encrypted, so it can be stored with the
+// metadata of the Vault in the Servers. Changing it never changes the master
+// key, so the chunks are never uploaded again.
+type Keyring struct {
+	Vault string `json:"vault"`
+	Slots []Slot `json:"slots"`
+}
+
+func (k *Keyring) index(name string) int {
+	for i, s := range k.Slots {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (k *Keyring) seal(master []byte, s Slot, secret []byte) error {
+	if k.index(s.Name) >= 0 || s.Name == "" {
+		return ErrSlot
+	}
+	if len(master) != KeySize {
+		return ErrKey
+	}
+	key, err := s.key(secret)
+	if err != nil {
+		return err
+	}
+	if s.Key, err = Seal(key, master, []byte(k.Vault+"\x00"+s.Name)); err != nil {
+		return err
+	}
+	k.Slots = append(k.Slots, s)
+	return nil
+}
+
+// scryptSlots returns the number of Scrypt Slots.
+func (k *Keyring) scryptSlots() (n int) {
+	for _, s := range k.Slots {
+		if s.KDF == Scrypt {
+			n++
+		}
+	}
+	return
+}
+
+// AddPassphrase adds a Scrypt Slot with the name that keeps master encrypted
+// with the passphrase, or returns ErrSlots.
+func (k *Keyring) AddPassphrase(master []byte, name string, passphrase []byte) error {
+	if k.scryptSlots() >= maxScryptSlots {
+		return ErrSlots
+	}
+	s := Slot{Name: name, KDF: Scrypt, Salt: make([]byte, 16), N: scryptN, R: 8, P: 1}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return err
+	}
+	return k.seal(master, s, passphrase)
+}
+
+// AddKey adds a Raw Slot with the name that keeps master encrypted with key,
+// which must be KeySize bytes long, like chunk.NewKey returns.
+func (k *Keyring) AddKey(master []byte, name string, key []byte) error {
+	return k.seal(master, Slot{Name: name, KDF: Raw}, key)
+}
+
+// Remove removes the Slot with the name.
+func (k *Keyring) Remove(name string) error {
+	i := k.index(name)
+	if i < 0 {
+		return ErrSlot
+	}
+	if len(k.Slots) == 1 {
+		return ErrLastSlot
+	}
+	k.Slots = append(k.Slots[:i], k.Slots[i+1:]...)
+	return nil
+}
+
+// Unlock returns the master key and the name of the first Slot that secret
+// unlocks, or ErrLocked. It tries only the first Scrypt Slots that
+// AddPassphrase allows, because the Keyring may come from an untrusted Server.
+func (k *Keyring) Unlock(secret []byte) (master []byte, name string, err error) {
+	n := 0
+	for _, s := range k.Slots {
+		if s.KDF == Scrypt {
+			if n == maxScryptSlots {
+				continue
+			}
+			n++
+		}
+		key, err := s.key(secret)
+		if err != nil {
+			continue
+		}
+		if master, err := Open(key, s.Key, []byte(k.Vault+"\x00"+s.Name)); err == nil {
+			return master, s.Name, nil
+		}
+	}
+	return nil, "", ErrLocked
+}
+
+// rotate replaces the Slot that secret unlocks with the one that add adds to
+// an empty Keyring with its name, and returns the name. The other Slots are
+// kept, and k is not modified if it fails.
+func (k *Keyring) rotate(secret, newSecret []byte, add func(r *Keyring, master []byte, name string) error) (string, error) {
+	if bytes.Equal(secret, newSecret) {
+		return "", ErrRotate
+	}
+	master, name, err := k.Unlock(secret)
+	if err != nil {
+		return "", err
+	}
+	r := Keyring{Vault: k.Vault}
+	if err := add(&r, master, name); err != nil {
+		return "", err
+	}
+	slots := append([]Slot(nil), k.Slots...)
+	slots[k.index(name)] = r.Slots[0]
+	k.Slots = slots
+	return name, nil
+}
+
+// RotatePassphrase replaces the Slot that secret unlocks with a Scrypt Slot
+// with the same name that keeps the master key encrypted with passphrase, so
+// that secret does not unlock the Keyring anymore. The master key does not
+// change, so the copies of the Keyring made before keep their Slots.
+func (k *Keyring) RotatePassphrase(secret, passphrase []byte) (string, error) {
+	return k.rotate(secret, passphrase, func(r *Keyring, master []byte, name string) error {
+		if k.Slots[k.index(name)].KDF != Scrypt && k.scryptSlots() >= maxScryptSlots {
+			return ErrSlots
+		}
+		return r.AddPassphrase(master, name, passphrase)
+	})
+}
+
+// RotateKey replaces the Slot that secret unlocks with a Raw Slot with the
+// same name that keeps the master key encrypted with key, like
+// RotatePassphrase.
+func (k *Keyring) RotateKey(secret, key []byte) (string, error) {
+	return k.rotate(secret, key, func(r *Keyring, master []byte, name string) error {
+		return r.AddKey(master, name, key)
+	})
+}
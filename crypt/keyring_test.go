@@ -0,0 +1,183 @@
+package crypt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func init() {
+	scryptN = 1 << 4
+}
+
+func TestKeyring(t *testing.T) {
+	master, err := NewMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &Keyring{Vault: "v1"}
+	if err := k.AddPassphrase(master, "alice", []byte("pass 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddKey(master, "laptop", key); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddPassphrase(master, "bob", []byte("pass 2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddKey(master, "laptop", key); err != ErrSlot {
+		t.Error(err)
+	}
+	if err := k.AddKey(master, "short", []byte("key")); err != ErrKey {
+		t.Error(err)
+	}
+	p, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(p, []byte("pass")) {
+		t.Error(string(p))
+	}
+	var k2 Keyring
+	if err := json.Unmarshal(p, &k2); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		secret []byte
+		name   string
+	}{
+		{[]byte("pass 1"), "alice"}, // 0
+		{key, "laptop"},             // 1
+		{[]byte("pass 2"), "bob"},   // 2
+	} {
+		m, name, err := k2.Unlock(c.secret)
+		if err != nil || name != c.name || !bytes.Equal(m, master) {
+			t.Error(i, name, err)
+		}
+	}
+	if _, _, err := k2.Unlock([]byte("pass 3")); err != ErrLocked {
+		t.Error(err)
+	}
+	k3 := k2
+	k3.Vault = "v2"
+	if _, _, err := k3.Unlock([]byte("pass 1")); err != ErrLocked {
+		t.Error(err)
+	}
+	if err := k2.Remove("bob"); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := k2.Unlock([]byte("pass 2")); err != ErrLocked {
+		t.Error(err)
+	}
+	if err := k2.Remove("bob"); err != ErrSlot {
+		t.Error(err)
+	}
+	old := k2.Slots[0].Key
+	if name, err := k2.RotatePassphrase([]byte("pass 1"), []byte("pass 3")); err != nil || name != "alice" {
+		t.Error(name, err)
+	}
+	if len(k2.Slots) != 2 || k2.Slots[0].Name != "alice" || bytes.Equal(k2.Slots[0].Key, old) {
+		t.Error(k2.Slots)
+	}
+	if _, _, err := k2.Unlock([]byte("pass 1")); err != ErrLocked {
+		t.Error(err)
+	}
+	if m, _, err := k2.Unlock([]byte("pass 3")); err != nil || !bytes.Equal(m, master) {
+		t.Error(err)
+	}
+	key2, err := NewMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := k2.RotateKey(key, key2); err != nil || name != "laptop" {
+		t.Error(name, err)
+	}
+	if _, _, err := k2.Unlock(key); err != ErrLocked {
+		t.Error(err)
+	}
+	if m, name, err := k2.Unlock(key2); err != nil || name != "laptop" || !bytes.Equal(m, master) {
+		t.Error(name, err)
+	}
+	if _, err := k2.RotateKey(key2, key2); err != ErrRotate {
+		t.Error(err)
+	}
+	if _, err := k2.RotateKey(key2, []byte("key")); err != ErrKey {
+		t.Error(err)
+	}
+	if _, _, err := k2.Unlock(key2); err != nil {
+		t.Error(err)
+	}
+	if err := k2.Remove("laptop"); err != nil {
+		t.Error(err)
+	}
+	if err := k2.Remove("alice"); err != ErrLastSlot {
+		t.Error(err)
+	}
+	if _, err := k2.RotatePassphrase([]byte("pass 2"), []byte("pass 4")); err != ErrLocked {
+		t.Error(err)
+	}
+}
+
+func TestKeyring_MaxScryptSlots(t *testing.T) {
+	master, err := NewMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &Keyring{Vault: "v1"}
+	if err := k.AddKey(master, "key", key); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxScryptSlots; i++ {
+		if err := k.AddPassphrase(master, fmt.Sprint(i), []byte(fmt.Sprint("pass ", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := k.AddPassphrase(master, "last", []byte("pass")); err != ErrSlots {
+		t.Error(err)
+	}
+	if _, err := k.RotatePassphrase(key, []byte("pass")); err != ErrSlots {
+		t.Error(err)
+	}
+	if _, err := k.RotatePassphrase([]byte("pass 0"), []byte("pass")); err != nil {
+		t.Error(err)
+	}
+	// A Keyring with more Scrypt Slots, which only an untrusted Server makes.
+	r := &Keyring{Vault: "v1"}
+	if err := r.AddPassphrase(master, "last", []byte("pass last")); err != nil {
+		t.Fatal(err)
+	}
+	k.Slots = append(k.Slots, r.Slots[0])
+	if _, _, err := k.Unlock([]byte("pass last")); err != ErrLocked {
+		t.Error(err)
+	}
+	if _, name, err := k.Unlock([]byte("pass 7")); err != nil || name != "7" {
+		t.Error(name, err)
+	}
+}
+
+func TestSlot_Limits(t *testing.T) {
+	for i, s := range []Slot{
+		{KDF: Scrypt, N: 1 << 30, R: 8, P: 1},       // 0
+		{KDF: Scrypt, N: 1 << 10, R: 1 << 20, P: 1}, // 1
+		{KDF: Scrypt, N: 1 << 10, R: 8, P: 1 << 20}, // 2
+		{KDF: Scrypt, N: 1 << 10, R: 0, P: 1},       // 3
+		{KDF: "argon2"},                             // 4
+	} {
+		if _, err := s.key([]byte("pass")); err != ErrKDF {
+			t.Error(i, err)
+		}
+		k := Keyring{Vault: "v1", Slots: []Slot{s}}
+		if _, _, err := k.Unlock([]byte("pass")); err != ErrLocked {
+			t.Error(i, err)
+		}
+	}
+}
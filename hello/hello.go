@@ -0,0 +1,33 @@
+// Package hello defines the handshake of the JSON RPC connections, which both
+// the Clients and the Servers implement.
+package hello
+
+import (
+	"errors"
+
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+// Method is the name of the JSON RPC method of the handshake. Its first param
+// is the session id chosen by the client, which is logged with all the
+// following requests of the connection to correlate them with the logs of the
+// client. The optional second param is the name of a Vault whose Metadata is
+// returned, so that the client can check that it chunks and hashes like the
+// Vault. It returns a Result.
+const Method = "Server.Hello"
+
+// Result is the result of the Method.
+type Result struct {
+	Session string `json:"session"`
+	Conn    uint64 `json:"conn"`
+
+	// Vault is the Metadata of the Vault requested by the client, if any.
+	Vault *vault.Metadata `json:"vault,omitempty"`
+}
+
+// ErrSession is returned by the Method when the session id is missing.
+var ErrSession = errors.New("hello: missing session id")
+
+// ErrVault is returned by the Method when the Vault param is invalid or the
+// Server has no Vaults.
+var ErrVault = errors.New("hello: invalid vault")
@@ -0,0 +1,387 @@
+package jrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Method handles the params of a Request or a Notification. The result is
+// ignored for Notifications.
+type Method func(ctx context.Context, params []interface{}) (result interface{}, err error)
+
+// Error is the error returned by Conn.Call() when the Response has an Error.
+type Error struct {
+	Value interface{}
+}
+
+func (e *Error) Error() string {
+	if s, ok := e.Value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(e.Value)
+}
+
+// ErrClosed is returned by Conn.Call() when Conn.Serve() has finished.
+var ErrClosed = errors.New("jrpc: connection closed")
+
+// ErrDeadPeer is returned by Conn.Serve() when nothing is received from the
+// peer for Conn.PingTimeout.
+var ErrDeadPeer = errors.New("jrpc: dead peer")
+
+// PingMethod and PongMethod are the names of the keepalive Notifications. They
+// are handled by Conn and never passed to its Methods.
+const (
+	PingMethod = "Conn.Ping"
+	PongMethod = "Conn.Pong"
+)
+
+// Conn is a peer of a JSON RPC v1 connection. It dispatches the Requests and
+// Notifications it receives to its Methods and it sends its own Requests and
+// Notifications.
+type Conn struct {
+	Ctx     context.Context
+	Conn    io.ReadWriter
+	Methods map[string]Method
+
+	// Timeout is the maximum time that a Method may take to handle a Request
+	// before an error Response is sent. It is not applied if it is zero.
+	Timeout time.Duration
+
+	// PingInterval is the period between the PingMethod Notifications sent to
+	// the peer, which answers each one with a PongMethod Notification. They are
+	// not sent if it is zero.
+	PingInterval time.Duration
+
+	// PingTimeout is the maximum time without receiving any message from the
+	// peer before it is considered dead. If it is zero, 3 * PingInterval is
+	// used. It is not applied if PingInterval is zero.
+	PingTimeout time.Duration
+
+	// Observe is called, if it is not nil, after each Request or Notification
+	// is handled by the Methods with its method name, duration and error.
+	Observe func(method string, d time.Duration, err error)
+
+	// Logger, if it is not nil, logs each Request and Notification handled by
+	// the Methods and each Request sent by Call().
+	Logger *slog.Logger
+
+	// Busy is called, if it is not nil, with true when the Methods start to
+	// handle a Request or a Notification and none was being handled, and with
+	// false when none is being handled anymore, like listen.Conn.Busy. A Method
+	// is handled until it returns, even after its Timeout.
+	Busy func(busy bool)
+
+	seen    int64
+	wg      sync.WaitGroup
+	busyMu  sync.Mutex
+	active  int
+	once    sync.Once
+	mu      sync.Mutex
+	enc     *Encoder
+	id      uint64
+	pending map[uint64]chan Response
+	done    chan struct{}
+	err     error
+}
+
+func (c *Conn) init() {
+	c.once.Do(func() {
+		c.enc = NewEncoder(c.Conn)
+		c.pending = make(map[uint64]chan Response)
+		c.done = make(chan struct{})
+	})
+}
+
+// Serve decodes messages from c.Conn until the context c.Ctx is done or there
+// is an error. Each Request and Notification is handled in a new goroutine by
+// the Method in c.Methods with the same name, and Responses are matched to
+// the pending calls of c.Call(). If c.Conn is an io.Closer, it is closed when
+// c.Ctx is done. It returns nil when the peer closes the connection, after
+// all Methods have returned.
+//
+// If c.PingInterval is not zero, the keepalive Notifications are sent
+// periodically and ErrDeadPeer is returned if the peer is silent for longer
+// than c.PingTimeout. If c.Conn is an io.Closer, it is also closed then.
+func (c *Conn) Serve() (err error) {
+	c.init()
+	ctx, cancel := context.WithCancel(c.Ctx)
+	defer func() {
+		cancel()
+		c.wg.Wait()
+		c.mu.Lock()
+		c.err = err
+		if c.err == nil {
+			c.err = ErrClosed
+		}
+		close(c.done)
+		c.mu.Unlock()
+	}()
+	stop := make(chan struct{})
+	defer close(stop)
+	dead := make(chan struct{})
+	if cl, ok := c.Conn.(io.Closer); ok {
+		go func() {
+			select {
+			case <-c.Ctx.Done():
+				cl.Close()
+			case <-dead:
+				cl.Close()
+			case <-stop:
+			}
+		}()
+	}
+	c.touch()
+	if c.PingInterval > 0 {
+		go c.heartbeat(stop, dead)
+	}
+	ponging := make(chan struct{}, 1)
+	dec := NewDecoder(c.Conn)
+	for {
+		m, err := dec.Decode()
+		if err != nil {
+			if c.Ctx.Err() != nil {
+				return c.Ctx.Err()
+			}
+			select {
+			case <-dead:
+				return ErrDeadPeer
+			default:
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		c.touch()
+		switch m := m.(type) {
+		case Request:
+			c.begin()
+			go func() {
+				defer c.end()
+				c.handle(ctx, m)
+			}()
+		case Notification:
+			switch m.Method {
+			case PingMethod:
+				c.keepalive(ponging, PongMethod)
+			case PongMethod:
+			default:
+				c.begin()
+				go func() {
+					defer c.end()
+					c.invoke(ctx, nil, m.Method, m.Params)
+				}()
+			}
+		case Response:
+			c.deliver(m)
+		}
+	}
+}
+
+// begin counts a goroutine that handles a message until the matching end.
+func (c *Conn) begin() {
+	c.wg.Add(1)
+	c.busyMu.Lock()
+	defer c.busyMu.Unlock()
+	c.active++
+	if c.active == 1 && c.Busy != nil {
+		c.Busy(true)
+	}
+}
+
+func (c *Conn) end() {
+	c.busyMu.Lock()
+	c.active--
+	if c.active == 0 && c.Busy != nil {
+		c.Busy(false)
+	}
+	c.busyMu.Unlock()
+	c.wg.Done()
+}
+
+func (c *Conn) touch() {
+	atomic.StoreInt64(&c.seen, time.Now().UnixNano())
+}
+
+// Seen returns the last time that a message was received from the peer, which
+// tells apart a slow peer that still answers the keepalive Notifications from
+// a dead one.
+func (c *Conn) Seen() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.seen))
+}
+
+func (c *Conn) heartbeat(stop <-chan struct{}, dead chan<- struct{}) {
+	timeout := c.PingTimeout
+	if timeout == 0 {
+		timeout = 3 * c.PingInterval
+	}
+	sending := make(chan struct{}, 1)
+	t := time.NewTicker(c.PingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if time.Since(c.Seen()) > timeout {
+				close(dead)
+				return
+			}
+			c.keepalive(sending, PingMethod)
+		}
+	}
+}
+
+// keepalive sends a Notification with the method in a new goroutine, unless
+// the previous one sent through sending is still being sent, because a slow
+// peer would pile them up and one is enough to keep the connection alive.
+func (c *Conn) keepalive(sending chan struct{}, method string) {
+	select {
+	case sending <- struct{}{}:
+		go func() {
+			c.Notify(method)
+			<-sending
+		}()
+	default:
+	}
+}
+
+func (c *Conn) handle(ctx context.Context, r Request) {
+	result, err := c.invoke(ctx, r.ID, r.Method, r.Params)
+	if err != nil {
+		c.send(func(e *Encoder) error {
+			return e.EncodeResponse(Response{ID: r.ID, Error: err.Error()})
+		})
+		return
+	}
+	c.send(func(e *Encoder) error {
+		return e.EncodeResponse(Response{ID: r.ID, Result: result})
+	})
+}
+
+func (c *Conn) log(msg string, id interface{}, method string, d time.Duration, err error) {
+	if c.Logger == nil {
+		return
+	}
+	if err != nil {
+		c.Logger.Warn(msg, "id", id, "method", method, "duration", d, "outcome", "error", "error", err.Error())
+		return
+	}
+	c.Logger.Info(msg, "id", id, "method", method, "duration", d, "outcome", "ok")
+}
+
+func (c *Conn) invoke(ctx context.Context, id interface{}, method string, params []interface{}) (result interface{}, err error) {
+	defer func(start time.Time) {
+		d := time.Since(start)
+		if c.Observe != nil {
+			c.Observe(method, d, err)
+		}
+		c.log("handled", id, method, d, err)
+	}(time.Now())
+	m, ok := c.Methods[method]
+	if !ok {
+		return nil, fmt.Errorf("jrpc: unknown method %q", method)
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	type ret struct {
+		result interface{}
+		err    error
+	}
+	rets := make(chan ret, 1)
+	// The Method may outlive the timeout, so it is counted on its own.
+	c.begin()
+	go func() {
+		defer c.end()
+		result, err := m(ctx, params)
+		rets <- ret{result, err}
+	}()
+	select {
+	case r := <-rets:
+		return r.result, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Conn) deliver(r Response) {
+	id, ok := r.ID.(float64)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	ch := c.pending[uint64(id)]
+	delete(c.pending, uint64(id))
+	c.mu.Unlock()
+	if ch != nil {
+		ch <- r
+	}
+}
+
+func (c *Conn) send(f func(*Encoder) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return f(c.enc)
+}
+
+// Call sends a Request with the method and params and waits for its Response
+// until the context ctx is done or c.Serve() finishes. It returns an *Error if
+// the Response has an Error, or the error that finished c.Serve() or
+// ErrClosed.
+func (c *Conn) Call(ctx context.Context, method string, params ...interface{}) (result interface{}, err error) {
+	c.init()
+	if params == nil {
+		params = []interface{}{}
+	}
+	ch := make(chan Response, 1)
+	c.mu.Lock()
+	id := c.id
+	c.id++
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func(start time.Time) {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		c.log("called", id, method, time.Since(start), err)
+	}(time.Now())
+	if err := c.send(func(e *Encoder) error {
+		return e.EncodeRequest(Request{ID: id, Method: method, Params: params})
+	}); err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-ch:
+		if r.Error != nil {
+			return nil, &Error{r.Error}
+		}
+		return r.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, c.err
+	}
+}
+
+// Notify sends a Notification with the method and params.
+func (c *Conn) Notify(method string, params ...interface{}) error {
+	c.init()
+	if params == nil {
+		params = []interface{}{}
+	}
+	return c.send(func(e *Encoder) error {
+		return e.EncodeNotification(Notification{Method: method, Params: params})
+	})
+}
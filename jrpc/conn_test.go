@@ -0,0 +1,206 @@
+package jrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const timeout = 100 * time.Millisecond
+
+func testConns(ctx context.Context, methods map[string]Method, timeout time.Duration) (*Conn, *Conn) {
+	c1, c2 := net.Pipe()
+	s := &Conn{Ctx: ctx, Conn: c1, Methods: methods, Timeout: timeout}
+	c := &Conn{Ctx: ctx, Conn: c2}
+	go s.Serve()
+	go c.Serve()
+	return s, c
+}
+
+func TestConn_Call(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	_, c := testConns(ctx, map[string]Method{
+		"add": func(ctx context.Context, params []interface{}) (interface{}, error) {
+			return params[0].(float64) + params[1].(float64), nil
+		},
+	}, 0)
+	if r, err := c.Call(ctx, "add", 1, 2); err != nil {
+		t.Error(err)
+	} else if r != 3.0 {
+		t.Error(r)
+	}
+	if _, err := c.Call(ctx, "sub", 1, 2); err == nil {
+		t.Error(err)
+	} else if s := err.Error(); s != `jrpc: unknown method "sub"` {
+		t.Error(s)
+	}
+}
+
+func TestConn_Timeout(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	_, c := testConns(ctx, map[string]Method{
+		"block": func(ctx context.Context, params []interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}, timeout)
+	if _, err := c.Call(ctx, "block"); err == nil {
+		t.Error(err)
+	} else if s := err.Error(); s != context.DeadlineExceeded.Error() {
+		t.Error(s)
+	}
+}
+
+func TestConn_TimeoutBusy(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	c1, c2 := net.Pipe()
+	returned := make(chan struct{})
+	var busy []bool
+	s := &Conn{Ctx: ctx, Conn: c1, Timeout: timeout, Methods: map[string]Method{
+		"slow": func(ctx context.Context, params []interface{}) (interface{}, error) {
+			<-ctx.Done()
+			time.Sleep(timeout)
+			close(returned)
+			return nil, nil
+		},
+	}, Busy: func(b bool) {
+		busy = append(busy, b)
+	}}
+	c := &Conn{Ctx: ctx, Conn: c2}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve()
+	}()
+	go c.Serve()
+	if _, err := c.Call(ctx, "slow"); err == nil || err.Error() != context.DeadlineExceeded.Error() {
+		t.Error(err)
+	}
+	c2.Close()
+	if err := <-served; err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-returned:
+	default:
+		t.Error("method still running")
+	}
+	if len(busy) != 2 || !busy[0] || busy[1] {
+		t.Error(busy)
+	}
+}
+
+func TestConn_Notify(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	notified := make(chan interface{}, 1)
+	_, c := testConns(ctx, map[string]Method{
+		"notify": func(ctx context.Context, params []interface{}) (interface{}, error) {
+			notified <- params[0]
+			return nil, nil
+		},
+	}, 0)
+	if err := c.Notify("notify", "a"); err != nil {
+		t.Error(err)
+	}
+	select {
+	case p := <-notified:
+		if p != "a" {
+			t.Error(p)
+		}
+	case <-ctx.Done():
+		t.Error(ctx.Err())
+	}
+}
+
+func TestConn_Closed(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	c1, c2 := net.Pipe()
+	c := &Conn{Ctx: ctx, Conn: c2}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Serve()
+	}()
+	c1.Close()
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+	if _, err := c.Call(ctx, "any"); err != ErrClosed {
+		t.Error(err)
+	}
+}
+
+func TestConn_Ping(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*timeout)
+	defer cancel()
+	c1, c2 := net.Pipe()
+	s := &Conn{Ctx: ctx, Conn: c1, Methods: map[string]Method{
+		"slow": func(ctx context.Context, params []interface{}) (interface{}, error) {
+			time.Sleep(5 * timeout)
+			return "done", nil
+		},
+	}}
+	c := &Conn{Ctx: ctx, Conn: c2, PingInterval: timeout / 2, PingTimeout: timeout}
+	go s.Serve()
+	go c.Serve()
+	if r, err := c.Call(ctx, "slow"); err != nil {
+		t.Error(err)
+	} else if r != "done" {
+		t.Error(r)
+	}
+	if s := time.Since(c.Seen()); s > timeout {
+		t.Error(s)
+	}
+}
+
+func TestConn_PingFlood(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*timeout)
+	defer cancel()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	c := &Conn{Ctx: ctx, Conn: c2}
+	n := runtime.NumGoroutine()
+	go c.Serve()
+	e := NewEncoder(c1)
+	for i := 0; i < 100; i++ {
+		if err := e.EncodeNotification(Notification{Method: PingMethod, Params: []interface{}{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Serve, the closer of c2 and a single Notify of PongMethod.
+	if d := runtime.NumGoroutine() - n; d > 3 {
+		t.Error(d)
+	}
+}
+
+func TestConn_DeadPeer(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*timeout)
+	defer cancel()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	go io.Copy(io.Discard, c1)
+	c := &Conn{Ctx: ctx, Conn: c2, PingInterval: timeout / 2, PingTimeout: timeout}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Serve()
+	}()
+	if _, err := c.Call(ctx, "any"); err != ErrDeadPeer {
+		t.Error(err)
+	}
+	if err := <-errs; err != ErrDeadPeer {
+		t.Error(err)
+	}
+}
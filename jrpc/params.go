@@ -0,0 +1,35 @@
+package jrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrParams is returned by DecodeParams when the params do not match.
+var ErrParams = errors.New("jrpc: invalid params")
+
+// Convert decodes v, a value decoded from JSON like the params of a Method or
+// the result of Conn.Call(), into the value pointed to by p.
+func Convert(v interface{}, p interface{}) error {
+	q, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(q, p)
+}
+
+// DecodeParams decodes each of the params into the value pointed to by the
+// element of ps with the same index. It returns an error that wraps ErrParams
+// if there are more or fewer params or they have other types.
+func DecodeParams(params []interface{}, ps ...interface{}) error {
+	if len(params) != len(ps) {
+		return fmt.Errorf("%w: %d params instead of %d", ErrParams, len(params), len(ps))
+	}
+	for i, p := range ps {
+		if err := Convert(params[i], p); err != nil {
+			return fmt.Errorf("%w: param %d: %v", ErrParams, i, err)
+		}
+	}
+	return nil
+}
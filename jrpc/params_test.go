@@ -0,0 +1,27 @@
+package jrpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeParams(t *testing.T) {
+	var name string
+	var tm time.Time
+	var p []byte
+	if err := DecodeParams([]interface{}{"v", "2024-01-02T03:04:05.000000006Z", "AQI="}, &name, &tm, &p); err != nil {
+		t.Error(err)
+	}
+	if name != "v" || tm.Nanosecond() != 6 || string(p) != "\x01\x02" {
+		t.Error(name, tm, p)
+	}
+	if err := DecodeParams([]interface{}{"v"}, &name, &tm); !errors.Is(err, ErrParams) {
+		t.Error(err)
+	}
+	if err := DecodeParams([]interface{}{1.0}, &name); !errors.Is(err, ErrParams) {
+		t.Error(err)
+	} else if s := err.Error(); s != "jrpc: invalid params: param 0: json: cannot unmarshal number into Go value of type string" {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,75 @@
+package listen
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Conn is a wrapper to net.Conn that moves the deadlines forward before each
+// Read and Write.
+type Conn struct {
+	net.Conn
+
+	// IdleTimeout is the maximum time that each Read may wait for data while
+	// the Conn is not busy. It is not applied if it is zero.
+	IdleTimeout time.Duration
+
+	// WriteTimeout is the maximum time that each Write may take. It is not
+	// applied if it is zero.
+	WriteTimeout time.Duration
+
+	mu   sync.Mutex
+	busy bool
+}
+
+// readDeadline sets the read deadline of c.Conn according to c.busy. c.mu
+// must be held.
+func (c *Conn) readDeadline() error {
+	if c.busy {
+		return c.Conn.SetReadDeadline(time.Time{})
+	}
+	return c.Conn.SetReadDeadline(time.Now().Add(c.IdleTimeout))
+}
+
+// Read calls c.Conn.SetReadDeadline() with time.Now().Add(c.IdleTimeout), or
+// with no deadline if c is busy, and then c.Conn.Read().
+func (c *Conn) Read(p []byte) (int, error) {
+	if c.IdleTimeout > 0 {
+		c.mu.Lock()
+		err := c.readDeadline()
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(p)
+}
+
+// Busy tells c whether requests are being handled, like jrpc.Conn.Busy does.
+// The IdleTimeout is suspended while c is busy and it starts again when c is
+// not, also for a Read that is already waiting.
+func (c *Conn) Busy(busy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.busy = busy
+	if c.IdleTimeout > 0 {
+		c.readDeadline()
+	}
+}
+
+// Write calls c.Conn.SetWriteDeadline() with time.Now().Add(c.WriteTimeout)
+// and then c.Conn.Write().
+func (c *Conn) Write(p []byte) (int, error) {
+	if c.WriteTimeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+// NetConn returns the wrapped c.Conn.
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
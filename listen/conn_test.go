@@ -0,0 +1,72 @@
+package listen
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConn_IdleTimeout(t *testing.T) {
+	t.Parallel()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	c := &Conn{Conn: c1, IdleTimeout: timeout}
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Error(err)
+	} else if !err.(net.Error).Timeout() {
+		t.Error(err)
+	}
+}
+
+func TestConn_WriteTimeout(t *testing.T) {
+	t.Parallel()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	c := &Conn{Conn: c1, WriteTimeout: timeout}
+	if _, err := c.Write(make([]byte, 1)); err == nil {
+		t.Error(err)
+	} else if !err.(net.Error).Timeout() {
+		t.Error(err)
+	}
+}
+
+func TestConn_NoTimeout(t *testing.T) {
+	t.Parallel()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	go c2.Write([]byte{1})
+	c := &Conn{Conn: c1, IdleTimeout: timeout, WriteTimeout: timeout}
+	if n, err := c.Read(make([]byte, 1)); err != nil {
+		t.Error(err)
+	} else if n != 1 {
+		t.Error(n)
+	}
+}
+
+func TestConn_Busy(t *testing.T) {
+	t.Parallel()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	c := &Conn{Conn: c1, IdleTimeout: timeout}
+	c.Busy(true)
+	go func() {
+		time.Sleep(2 * timeout)
+		c2.Write([]byte{1})
+	}()
+	if n, err := c.Read(make([]byte, 1)); err != nil || n != 1 {
+		t.Error(n, err)
+	}
+	go func() {
+		time.Sleep(timeout / 2)
+		c.Busy(false)
+	}()
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Error(err)
+	} else if !err.(net.Error).Timeout() {
+		t.Error(err)
+	}
+}
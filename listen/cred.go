@@ -0,0 +1,32 @@
+package listen
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrNoCred is returned by PeerCred when the credentials of the peer are not
+// available.
+var ErrNoCred = errors.New("listen: peer credentials not available")
+
+// Cred are the credentials of the process at the other end of a unix socket.
+type Cred struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// PeerCred returns the Cred of the peer of conn, which must be a *net.UnixConn
+// or wrap one with a NetConn() method like Conn does.
+func PeerCred(conn net.Conn) (Cred, error) {
+	for {
+		switch c := conn.(type) {
+		case *net.UnixConn:
+			return peerCred(c)
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return Cred{}, ErrNoCred
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package listen
+
+import (
+	"net"
+	"syscall"
+)
+
+func peerCred(c *net.UnixConn) (Cred, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return Cred{}, err
+	}
+	var ucred *syscall.Ucred
+	var uerr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, uerr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return Cred{}, err
+	}
+	if uerr != nil {
+		return Cred{}, uerr
+	}
+	return Cred{ucred.Pid, ucred.Uid, ucred.Gid}, nil
+}
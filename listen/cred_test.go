@@ -0,0 +1,51 @@
+package listen
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestPeerCred_Pipe(t *testing.T) {
+	t.Parallel()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	if _, err := PeerCred(&Conn{Conn: c1}); err != ErrNoCred {
+		t.Error(err)
+	}
+}
+
+func TestPeerCred_Unix(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip(runtime.GOOS)
+	}
+	l, err := net.Listen("unix", "./test-cred.socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := net.Dial("unix", "./test-cred.socket")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+	}()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c, err := PeerCred(&Conn{Conn: conn})
+	if err != nil {
+		t.Error(err)
+	} else if c.PID != int32(os.Getpid()) {
+		t.Error(c.PID)
+	} else if c.UID != uint32(os.Getuid()) {
+		t.Error(c.UID)
+	}
+}
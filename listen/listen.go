@@ -12,6 +12,15 @@ type Listener struct {
 	Listener net.Listener
 	Timeout  time.Duration
 	Serve    func(net.Conn)
+
+	// IdleTimeout is the maximum time that an accepted connection may wait for
+	// data to read while it is not busy, see Conn.Busy. It is not applied if it
+	// is zero.
+	IdleTimeout time.Duration
+
+	// WriteTimeout is the maximum time that each write to an accepted
+	// connection may take. It is not applied if it is zero.
+	WriteTimeout time.Duration
 }
 
 type deadlineSettable interface {
@@ -22,7 +31,9 @@ type deadlineSettable interface {
 // or there is an error. time.Now().Add(l.Timeout) is passed to
 // l.Listener.SetDeadline() before each call to Accept(), and errors caused by
 // this deadline are ignored. Every accepted connection is passed to the
-// callback l.Serve() in a new goroutine that closes it afterwards.
+// callback l.Serve() in a new goroutine that closes it afterwards. If
+// l.IdleTimeout or l.WriteTimeout are not zero, the connection is wrapped in a
+// Conn that applies them.
 func (l *Listener) Listen() error {
 	ds := l.Listener.(deadlineSettable)
 	for {
@@ -41,6 +52,9 @@ func (l *Listener) Listen() error {
 				}
 				return err
 			}
+			if l.IdleTimeout > 0 || l.WriteTimeout > 0 {
+				conn = &Conn{Conn: conn, IdleTimeout: l.IdleTimeout, WriteTimeout: l.WriteTimeout}
+			}
 			go func() {
 				defer conn.Close()
 				l.Serve(conn)
@@ -18,7 +18,7 @@ func TestListen_NoConn(t *testing.T) {
 		t.Error(err)
 	}
 	defer l.Close()
-	ll := Listener{ctx, l, timeout, nil}
+	ll := Listener{Ctx: ctx, Listener: l, Timeout: timeout}
 	if err := ll.Listen(); err != context.DeadlineExceeded {
 		t.Error(err)
 	}
@@ -45,7 +45,7 @@ func TestListen_TCPConn(t *testing.T) {
 	serve := func(conn net.Conn) {
 		conns <- conn
 	}
-	ll := Listener{ctx, l, timeout, serve}
+	ll := Listener{Ctx: ctx, Listener: l, Timeout: timeout, Serve: serve}
 	if err := ll.Listen(); err != context.DeadlineExceeded {
 		t.Error(err)
 	}
@@ -77,7 +77,7 @@ func TestListen_UnixConn(t *testing.T) {
 	serve := func(conn net.Conn) {
 		conns <- conn
 	}
-	ll := Listener{ctx, l, timeout, serve}
+	ll := Listener{Ctx: ctx, Listener: l, Timeout: timeout, Serve: serve}
 	if err := ll.Listen(); err != context.DeadlineExceeded {
 		t.Error(err)
 	}
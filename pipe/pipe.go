@@ -0,0 +1,160 @@
+// Package pipe implements an in-memory transport of net.Pipe connections that
+// are announced and dialed by address within the process.
+package pipe
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Network is the name of the network of the addresses.
+const Network = "pipe"
+
+// ErrAddrInUse is returned by Listen when the address is already announced.
+var ErrAddrInUse = errors.New("pipe: address already in use")
+
+// ErrRefused is returned by DialTimeout when the address is not announced.
+var ErrRefused = errors.New("pipe: connection refused")
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "pipe: i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Addr is the net.Addr of a Listener.
+type Addr string
+
+// Network returns Network.
+func (a Addr) Network() string {
+	return Network
+}
+
+func (a Addr) String() string {
+	return string(a)
+}
+
+type conn struct {
+	net.Conn
+	addr Addr
+}
+
+func (c conn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c conn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+var listeners = struct {
+	sync.Mutex
+	m map[string]*Listener
+}{m: make(map[string]*Listener)}
+
+// Listener is a net.Listener that accepts the connections dialed by
+// DialTimeout. It also implements SetDeadline as listen.Listener requires.
+type Listener struct {
+	addr     Addr
+	conns    chan net.Conn
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// Listen announces on the address. The network is ignored.
+func Listen(network, address string) (net.Listener, error) {
+	listeners.Lock()
+	defer listeners.Unlock()
+	if _, ok := listeners.m[address]; ok {
+		return nil, ErrAddrInUse
+	}
+	l := &Listener{
+		addr:  Addr(address),
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	listeners.m[address] = l
+	return l, nil
+}
+
+// Accept waits for the next connection until the listener is closed or the
+// deadline is exceeded.
+func (l *Listener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	deadline := l.deadline
+	l.mu.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		t := time.NewTimer(time.Until(deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	case <-timeout:
+		return nil, timeoutError{}
+	}
+}
+
+// SetDeadline sets the deadline of the next calls to Accept. A zero value
+// disables it.
+func (l *Listener) SetDeadline(t time.Time) error {
+	l.mu.Lock()
+	l.deadline = t
+	l.mu.Unlock()
+	return nil
+}
+
+// Close stops announcing the address.
+func (l *Listener) Close() error {
+	l.once.Do(func() {
+		listeners.Lock()
+		delete(listeners.m, string(l.addr))
+		listeners.Unlock()
+		close(l.done)
+	})
+	return nil
+}
+
+// Addr returns the address as an Addr.
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
+
+// DialTimeout connects to the address with a net.Pipe, waiting for the
+// Listener to accept it for the timeout at most. The network is ignored. Both
+// ends have the address as their local and remote Addr.
+func DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	listeners.Lock()
+	l := listeners.m[address]
+	listeners.Unlock()
+	if l == nil {
+		return nil, ErrRefused
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	c1, c2 := net.Pipe()
+	select {
+	case l.conns <- conn{c1, l.addr}:
+		return conn{c2, l.addr}, nil
+	case <-l.done:
+		c1.Close()
+		c2.Close()
+		return nil, ErrRefused
+	case <-expired:
+		c1.Close()
+		c2.Close()
+		return nil, timeoutError{}
+	}
+}
@@ -0,0 +1,120 @@
+package pipe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/listen"
+)
+
+const timeout = 100 * time.Millisecond
+
+func TestListen_AddrInUse(t *testing.T) {
+	t.Parallel()
+	l, err := Listen(Network, "TestListen_AddrInUse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(Network, "TestListen_AddrInUse"); err != ErrAddrInUse {
+		t.Error(err)
+	}
+	l.Close()
+	l, err = Listen(Network, "TestListen_AddrInUse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
+
+func TestDialTimeout_Refused(t *testing.T) {
+	t.Parallel()
+	if _, err := DialTimeout(Network, "TestDialTimeout_Refused", timeout); err != ErrRefused {
+		t.Error(err)
+	}
+}
+
+func TestDialTimeout_Timeout(t *testing.T) {
+	t.Parallel()
+	l, err := Listen(Network, "TestDialTimeout_Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := DialTimeout(Network, "TestDialTimeout_Timeout", timeout); err == nil {
+		t.Error(err)
+	} else if !err.(net.Error).Timeout() {
+		t.Error(err)
+	}
+}
+
+func TestListener_Deadline(t *testing.T) {
+	t.Parallel()
+	l, err := Listen(Network, "TestListener_Deadline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.(*Listener).SetDeadline(time.Now().Add(timeout))
+	if _, err := l.Accept(); err == nil {
+		t.Error(err)
+	} else if !err.(net.Error).Timeout() {
+		t.Error(err)
+	}
+}
+
+func TestListener_Closed(t *testing.T) {
+	t.Parallel()
+	l, err := Listen(Network, "TestListener_Closed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err := l.Accept(); err != net.ErrClosed {
+		t.Error(err)
+	}
+}
+
+func TestListener_Listen(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	l, err := Listen(Network, "TestListener_Listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if s := l.Addr().String(); s != "TestListener_Listen" {
+		t.Error(s)
+	}
+	go func() {
+		conn, err := DialTimeout(Network, "TestListener_Listen", timeout)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte{1})
+	}()
+	bytes := make(chan byte, 1)
+	serve := func(conn net.Conn) {
+		p := make([]byte, 1)
+		if _, err := conn.Read(p); err != nil {
+			t.Error(err)
+		}
+		bytes <- p[0]
+	}
+	ll := listen.Listener{Ctx: ctx, Listener: l, Timeout: timeout, Serve: serve}
+	if err := ll.Listen(); err != context.DeadlineExceeded {
+		t.Error(err)
+	}
+	select {
+	case b := <-bytes:
+		if b != 1 {
+			t.Error(b)
+		}
+	case <-time.After(timeout):
+		t.Error()
+	}
+}
@@ -0,0 +1,171 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/backend/mem"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/client"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/server"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+// session dials the Server at the address with a backend.Client, retrying
+// until it listens.
+func session(t *testing.T, address string, check func(vault.Metadata) error, f func(*backend.Client) error) {
+	c := &client.Client{
+		Network:   Network,
+		Address:   address,
+		Timeout:   timeout,
+		Connect:   DialTimeout,
+		SessionID: "test",
+		Vault:     "v",
+		Check:     check,
+		Session: func(conn *jrpc.Conn) error {
+			return f(&backend.Client{Client: vault.Client{Conn: conn}})
+		},
+	}
+	for i := 0; ; i++ {
+		err := c.Dial()
+		if err != ErrRefused || i == 10 {
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+		time.Sleep(timeout / 10)
+	}
+}
+
+// TestRoundTrip uploads a file to a Server through a pipe and downloads it
+// back, like floc-upload and floc-download do.
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &backend.Service{Backend: &mem.Backend{}}
+	srv := &server.Server{
+		Ctx:      ctx,
+		Network:  Network,
+		Address:  "TestRoundTrip",
+		Timeout:  timeout,
+		Methods:  s.Methods(),
+		Vault:    s.Vault,
+		Announce: Listen,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Listen()
+	}()
+	config := split.Config{Algorithm: split.FastCDC, Min: 1 << 10, Avg: 1 << 12, Max: 1 << 14}
+	h := chunk.Hasher{Algorithm: chunk.SHA256}
+	m, err := vault.NewMetadata("v", config, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateVault(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	check := func(m vault.Metadata) error {
+		return m.Check(config, h)
+	}
+	data := make([]byte, 1<<18)
+	rand.New(rand.NewSource(1)).Read(data)
+	copy(data[1<<17:], data[:1<<16])
+
+	var archive vault.Archive
+	session(t, "TestRoundTrip", check, func(c *backend.Client) error {
+		sp, err := config.Splitter()
+		if err != nil {
+			return err
+		}
+		ch := &split.Chunker{Split: sp, Hasher: h, R: bytes.NewReader(data)}
+		defer ch.Close()
+		entry := vault.Entry{Path: "file"}
+		stored := 0
+		for {
+			k, err := ch.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			r, err := chunk.NewRecord(h, k.Data, chunk.Flate)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if _, err := r.WriteTo(&buf); err != nil {
+				return err
+			}
+			ok, err := c.PutChunk(ctx, buf.Bytes())
+			if err != nil {
+				return err
+			}
+			if ok {
+				stored++
+			}
+			entry.Chunks = append(entry.Chunks, vault.ChunkRef{ID: k.ID, Offset: k.Offset, Length: k.Data.N})
+			k.Release()
+		}
+		if stored == len(entry.Chunks) {
+			t.Error("nothing deduplicated", stored)
+		}
+		a, err := c.BeginArchive(ctx, "v")
+		if err != nil {
+			return err
+		}
+		if err := c.AppendArchive(ctx, "v", a.Time, []vault.Entry{entry}); err != nil {
+			return err
+		}
+		archive, err = c.CommitArchive(ctx, "v", a.Time)
+		return err
+	})
+
+	var out bytes.Buffer
+	session(t, "TestRoundTrip", check, func(c *backend.Client) error {
+		a, err := c.GetArchive(ctx, "v", archive.Time)
+		if err != nil {
+			return err
+		}
+		if a.State != vault.Complete || len(a.Entries) != 1 {
+			t.Fatal(a.State, len(a.Entries))
+		}
+		for _, ref := range a.Entries[0].Chunks {
+			p, err := c.GetChunk(ctx, ref.ID)
+			if err != nil {
+				return err
+			}
+			var r chunk.Record
+			if _, err := r.ReadFrom(bytes.NewReader(p)); err != nil {
+				return err
+			}
+			f, err := r.Data(h)
+			if err != nil {
+				return err
+			}
+			if int64(out.Len()) != ref.Offset || f.N != ref.Length {
+				t.Error(ref)
+			}
+			out.Write(f.Bytes())
+			f.Release()
+			r.Release()
+		}
+		return nil
+	})
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Error(out.Len())
+	}
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+}
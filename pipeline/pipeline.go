@@ -0,0 +1,211 @@
+// Package pipeline chunks, hashes and looks up many files concurrently, with
+// each stage in its own goroutines.
+package pipeline
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+)
+
+// File is an input of a Pipeline.
+type File struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+}
+
+// Result is a Chunk of a File, or the end of a File when EOF is true.
+type Result struct {
+	File File
+
+	// Chunk has its ID. It must be released by the receiver.
+	Chunk split.Chunk
+
+	// Known is the answer of Pipeline.Lookup for the ID.
+	Known bool
+
+	// EOF is true for the last Result of each File, which has no Chunk.
+	EOF bool
+
+	// Err is the error of the File that stopped reading it, which is given in
+	// the last Result, or the error of hashing or looking up the Chunk.
+	Err error
+}
+
+// Pipeline reads and splits Files, hashes the Chunks and looks up their IDs in
+// separate stages. The Results of each File are delivered in order, but the
+// Results of different Files are interleaved.
+type Pipeline struct {
+
+	// Config is the configuration of the Splitter of each File.
+	Config split.Config
+
+	// Hasher computes the IDs. It is chunk.SHA256 if its Algorithm is zero.
+	Hasher chunk.Hasher
+
+	// Lookup, if it is not nil, tells whether a Chunk is already stored.
+	Lookup func(ctx context.Context, id chunk.ID) (bool, error)
+
+	// Readers is the number of Files read and split at the same time. It is 1
+	// if it is zero.
+	Readers int
+
+	// Hashers is the number of goroutines that hash Chunks. It is
+	// runtime.NumCPU() if it is zero.
+	Hashers int
+
+	// Lookups is the number of goroutines that call Lookup. It is 1 if it is
+	// zero.
+	Lookups int
+
+	// Depth is the maximum number of Results pending delivery, which bounds the
+	// memory held. It is 64 if it is zero.
+	Depth int
+}
+
+type job struct {
+	r    Result
+	done chan struct{}
+}
+
+func orDefault(n, d int) int {
+	if n <= 0 {
+		return d
+	}
+	return n
+}
+
+// Run processes the files and calls out with each Result from the calling
+// goroutine. If out returns an error, the pipeline is stopped, the pending
+// Chunks are released and Run returns it. Run also stops when ctx is done.
+func (p *Pipeline) Run(ctx context.Context, files []File, out func(Result) error) error {
+	if _, err := p.Config.Splitter(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := p.Hasher
+	if h.Algorithm == 0 {
+		h.Algorithm = chunk.SHA256
+	}
+	results := make(chan *job, orDefault(p.Depth, 64))
+	hashes := make(chan *job, orDefault(p.Hashers, runtime.NumCPU()))
+	lookups := make(chan *job, orDefault(p.Lookups, 1))
+	names := make(chan File)
+
+	var readers sync.WaitGroup
+	for i := 0; i < orDefault(p.Readers, 1); i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for f := range names {
+				p.read(ctx, f, results, hashes)
+			}
+		}()
+	}
+	go func() {
+		defer func() {
+			close(names)
+			readers.Wait()
+			close(results)
+			close(hashes)
+		}()
+		for _, f := range files {
+			select {
+			case names <- f:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var hashers sync.WaitGroup
+	for i := 0; i < orDefault(p.Hashers, runtime.NumCPU()); i++ {
+		hashers.Add(1)
+		go func() {
+			defer hashers.Done()
+			for j := range hashes {
+				if j.r.Err = ctx.Err(); j.r.Err == nil {
+					j.r.Chunk.ID, j.r.Err = h.Sum(j.r.Chunk.Data)
+				}
+				if j.r.Err != nil || p.Lookup == nil {
+					close(j.done)
+					continue
+				}
+				lookups <- j
+			}
+		}()
+	}
+	go func() {
+		hashers.Wait()
+		close(lookups)
+	}()
+	for i := 0; i < orDefault(p.Lookups, 1); i++ {
+		go func() {
+			for j := range lookups {
+				j.r.Known, j.r.Err = p.Lookup(ctx, j.r.Chunk.ID)
+				close(j.done)
+			}
+		}()
+	}
+
+	var err error
+	for j := range results {
+		<-j.done
+		if err != nil {
+			j.r.Chunk.Release()
+			continue
+		}
+		if err = out(j.r); err != nil {
+			cancel()
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// read splits f and sends its Chunks to hashes in order, and to results as
+// long as ctx is not done. Each job is sent to results before hashes, so that
+// every delivered job is eventually done.
+func (p *Pipeline) read(ctx context.Context, f File, results, hashes chan<- *job) {
+	end := &job{Result{File: f, EOF: true}, make(chan struct{})}
+	close(end.done)
+	defer func() {
+		select {
+		case results <- end:
+		case <-ctx.Done():
+		}
+	}()
+	rc, err := f.Open()
+	if err != nil {
+		end.r.Err = err
+		return
+	}
+	defer rc.Close()
+	s, _ := p.Config.Splitter()
+	c := &split.Chunker{Split: s, R: rc}
+	defer c.Close()
+	for {
+		ch, err := c.Cut()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			end.r.Err = err
+			return
+		}
+		j := &job{Result{File: f, Chunk: ch}, make(chan struct{})}
+		select {
+		case results <- j:
+		case <-ctx.Done():
+			ch.Release()
+			return
+		}
+		hashes <- j
+	}
+}
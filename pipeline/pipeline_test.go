@@ -0,0 +1,170 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+)
+
+var testConfig = split.Config{Algorithm: split.FastCDC, Avg: 1 << 12}
+
+func testFiles(n int) ([]File, [][]byte) {
+	rnd := rand.New(rand.NewSource(1))
+	files := make([]File, n)
+	data := make([][]byte, n)
+	for i := range files {
+		p := make([]byte, rnd.Intn(1<<18))
+		rnd.Read(p)
+		data[i] = p
+		files[i] = File{fmt.Sprint("f", i), func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(p)), nil
+		}}
+	}
+	return files, data
+}
+
+func serial(t *testing.T, p []byte) []chunk.ID {
+	s, _ := testConfig.Splitter()
+	c := &split.Chunker{Split: s, R: bytes.NewReader(p)}
+	var ids []chunk.ID
+	for {
+		ch, err := c.Next()
+		if err == io.EOF {
+			return ids
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, ch.ID)
+		ch.Release()
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	inUse := buffers.InUse()
+	files, data := testFiles(8)
+	var mu sync.Mutex
+	seen := make(map[chunk.ID]bool)
+	p := &Pipeline{
+		Config:  testConfig,
+		Readers: 3,
+		Hashers: 4,
+		Lookups: 2,
+		Depth:   5,
+		Lookup: func(ctx context.Context, id chunk.ID) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			known := seen[id]
+			seen[id] = true
+			return known, nil
+		},
+	}
+	got := make(map[string][]chunk.ID)
+	offs := make(map[string]int64)
+	eofs := make(map[string]bool)
+	known := 0
+	if err := p.Run(context.Background(), files, func(r Result) error {
+		if r.Err != nil {
+			t.Error(r.File.Name, r.Err)
+		}
+		if eofs[r.File.Name] {
+			t.Error(r.File.Name)
+		}
+		if r.EOF {
+			eofs[r.File.Name] = true
+			return nil
+		}
+		if r.Chunk.Offset != offs[r.File.Name] {
+			t.Error(r.File.Name, r.Chunk.Offset)
+		}
+		offs[r.File.Name] += int64(r.Chunk.Data.N)
+		got[r.File.Name] = append(got[r.File.Name], r.Chunk.ID)
+		if r.Known {
+			known++
+		}
+		r.Chunk.Release()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for i, f := range files {
+		want := serial(t, data[i])
+		total += len(want)
+		if !eofs[f.Name] {
+			t.Error(i)
+		}
+		if fmt.Sprint(got[f.Name]) != fmt.Sprint(want) {
+			t.Error(i, len(got[f.Name]), len(want))
+		}
+	}
+	if known != total-len(seen) {
+		t.Error(known, total, len(seen))
+	}
+	if n := buffers.InUse() - inUse; n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestPipeline_Stop(t *testing.T) {
+	inUse := buffers.InUse()
+	files, _ := testFiles(8)
+	stop := errors.New("stop")
+	p := &Pipeline{Config: testConfig, Readers: 4, Depth: 2}
+	n := 0
+	if err := p.Run(context.Background(), files, func(r Result) error {
+		r.Chunk.Release()
+		if n++; n == 10 {
+			return stop
+		}
+		return nil
+	}); err != stop {
+		t.Error(err)
+	}
+	if n != 10 {
+		t.Error(n)
+	}
+	if n := buffers.InUse() - inUse; n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestPipeline_Errors(t *testing.T) {
+	fail := errors.New("fail")
+	files := []File{{"a", func() (io.ReadCloser, error) {
+		return nil, fail
+	}}}
+	p := &Pipeline{Config: testConfig}
+	var results []Result
+	if err := p.Run(context.Background(), files, func(r Result) error {
+		results = append(results, r)
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+	if len(results) != 1 || !results[0].EOF || results[0].Err != fail {
+		t.Error(results)
+	}
+	p.Config = split.Config{}
+	if err := p.Run(context.Background(), files, nil); err != split.ErrAlgorithm {
+		t.Error(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	files, _ = testFiles(2)
+	p.Config = testConfig
+	if err := p.Run(ctx, files, func(r Result) error {
+		r.Chunk.Release()
+		return nil
+	}); err != context.Canceled {
+		t.Error(err)
+	}
+}
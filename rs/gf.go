@@ -0,0 +1,132 @@
+package rs
+
+// The arithmetic of GF(2^8) with the polynomial x^8 + x^4 + x^3 + x^2 + 1 and
+// the generator 2.
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+	gfMul [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i], gfExp[i+255] = byte(x), byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			gfMul[a][b] = gfExp[int(gfLog[a])+int(gfLog[b])]
+		}
+	}
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+func gfPow(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])*n)%255]
+}
+
+type matrix [][]byte
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for i := range m {
+		m[i] = make([]byte, cols)
+	}
+	return m
+}
+
+// vandermonde returns the matrix with the element r^c in the row r and the
+// column c, whose square submatrices of any rows are invertible.
+func vandermonde(rows, cols int) matrix {
+	m := newMatrix(rows, cols)
+	for r := range m {
+		for c := range m[r] {
+			m[r][c] = gfPow(byte(r), c)
+		}
+	}
+	return m
+}
+
+func (m matrix) mul(n matrix) matrix {
+	p := newMatrix(len(m), len(n[0]))
+	for r := range p {
+		for c := range p[r] {
+			var v byte
+			for i := range n {
+				v ^= gfMul[m[r][i]][n[i][c]]
+			}
+			p[r][c] = v
+		}
+	}
+	return p
+}
+
+// invert returns the inverse of the square matrix m with Gauss-Jordan
+// elimination, or false if it is singular.
+func (m matrix) invert() (matrix, bool) {
+	n := len(m)
+	w := newMatrix(n, 2*n)
+	for r := range m {
+		copy(w[r], m[r])
+		w[r][n+r] = 1
+	}
+	for c := 0; c < n; c++ {
+		p := c
+		for p < n && w[p][c] == 0 {
+			p++
+		}
+		if p == n {
+			return nil, false
+		}
+		w[c], w[p] = w[p], w[c]
+		if v := w[c][c]; v != 1 {
+			inv := gfInv(v)
+			for i := range w[c] {
+				w[c][i] = gfMul[w[c][i]][inv]
+			}
+		}
+		for r := 0; r < n; r++ {
+			if v := w[r][c]; r != c && v != 0 {
+				for i := range w[r] {
+					w[r][i] ^= gfMul[v][w[c][i]]
+				}
+			}
+		}
+	}
+	inv := newMatrix(n, n)
+	for r := range inv {
+		copy(inv[r], w[r][n:])
+	}
+	return inv, true
+}
+
+// mulAdd adds to q the product of c and p byte by byte.
+func mulAdd(c byte, p, q []byte) {
+	switch c {
+	case 0:
+	case 1:
+		for i, v := range p {
+			q[i] ^= v
+		}
+	default:
+		t := &gfMul[c]
+		for i, v := range p {
+			q[i] ^= t[v]
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package rs implements a systematic Reed-Solomon erasure code over GF(2^8)
+// for the chunks of data streams.
+package rs
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+var (
+	// ErrShards is returned when the number of shards is not valid.
+	ErrShards = errors.New("rs: invalid number of shards")
+
+	// ErrShardSize is returned when the shards do not have the same length.
+	ErrShardSize = errors.New("rs: shards of different sizes")
+
+	// ErrTooFewShards is returned by Reconstruct when less than Data shards
+	// are present.
+	ErrTooFewShards = errors.New("rs: too few shards to reconstruct")
+)
+
+// Encoder computes Parity shards from Data shards such that any Data shards
+// of all of them are enough to reconstruct the others.
+type Encoder struct {
+	Data   int
+	Parity int
+
+	// m is the encoding matrix, with the identity in the first Data rows.
+	m matrix
+}
+
+// New returns an Encoder of data and parity shards, which must be positive
+// numbers that add up to 256 at most.
+func New(data, parity int) (*Encoder, error) {
+	if data <= 0 || parity <= 0 || data+parity > 256 {
+		return nil, ErrShards
+	}
+	v := vandermonde(data+parity, data)
+	top, _ := v[:data].invert()
+	return &Encoder{data, parity, v.mul(top)}, nil
+}
+
+func (e *Encoder) size(shards [][]byte) (int, error) {
+	if len(shards) != e.Data+e.Parity {
+		return 0, ErrShards
+	}
+	size := -1
+	for _, p := range shards {
+		if p == nil {
+			continue
+		}
+		if size < 0 {
+			size = len(p)
+		} else if len(p) != size {
+			return 0, ErrShardSize
+		}
+	}
+	return size, nil
+}
+
+// Encode computes the Parity shards, which are the last ones of shards, from
+// the Data shards, which are the first ones and must have the same length.
+// The Parity shards are allocated if they are nil.
+func (e *Encoder) Encode(shards [][]byte) error {
+	size, err := e.size(shards)
+	if err != nil {
+		return err
+	}
+	for _, p := range shards[:e.Data] {
+		if p == nil {
+			return ErrTooFewShards
+		}
+	}
+	for r := e.Data; r < len(shards); r++ {
+		e.recompute(shards, r, size)
+	}
+	return nil
+}
+
+// Reconstruct computes the missing shards, which are those that are nil, from
+// the others. At least Data shards must be present.
+func (e *Encoder) Reconstruct(shards [][]byte) error {
+	size, err := e.size(shards)
+	if err != nil {
+		return err
+	}
+	var rows []int
+	for i, p := range shards {
+		if p != nil && len(rows) < e.Data {
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) < e.Data {
+		return ErrTooFewShards
+	}
+	var missing []int
+	for i, p := range shards[:e.Data] {
+		if p == nil {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		sub := make(matrix, e.Data)
+		for i, r := range rows {
+			sub[i] = e.m[r]
+		}
+		inv, _ := sub.invert()
+		for _, r := range missing {
+			p := make([]byte, size)
+			for c, i := range rows {
+				mulAdd(inv[r][c], shards[i], p)
+			}
+			shards[r] = p
+		}
+	}
+	for r := e.Data; r < len(shards); r++ {
+		if shards[r] == nil {
+			e.recompute(shards, r, size)
+		}
+	}
+	return nil
+}
+
+// recompute computes the shard r from the Data shards.
+func (e *Encoder) recompute(shards [][]byte, r, size int) {
+	p := shards[r]
+	if p == nil {
+		p = make([]byte, size)
+	} else {
+		for i := range p {
+			p[i] = 0
+		}
+	}
+	for c, q := range shards[:e.Data] {
+		mulAdd(e.m[r][c], q, p)
+	}
+	shards[r] = p
+}
+
+// Split returns Data shards with the bytes of f, padded with zeros, followed
+// by Parity empty slots for Encode. It does not take references to the
+// segments of f.
+func (e *Encoder) Split(f buffers.Buffers) [][]byte {
+	size := (f.N + e.Data - 1) / e.Data
+	if size == 0 {
+		size = 1
+	}
+	shards := make([][]byte, e.Data+e.Parity)
+	for i := range shards[:e.Data] {
+		shards[i] = make([]byte, size)
+	}
+	i, j := 0, 0
+	for _, p := range f.S {
+		for len(p) > 0 {
+			n := copy(shards[i][j:], p)
+			p, j = p[n:], j+n
+			if j == size {
+				i, j = i+1, 0
+			}
+		}
+	}
+	return shards
+}
+
+// Join returns the first n bytes of the Data shards.
+func (e *Encoder) Join(shards [][]byte, n int) (f buffers.Buffers) {
+	for _, p := range shards[:e.Data] {
+		if n < len(p) {
+			p = p[:n]
+		}
+		f, n = f.Append(p), n-len(p)
+	}
+	return
+}
+
+// Checksum returns the FNV-1a hash of p, which is stored with each shard to
+// detect the damaged ones.
+func Checksum(p []byte) uint32 {
+	return binary.BigEndian.Uint32(buffers.Buffers{}.Append(p).Hash32(nil))
+}
+
+// Checksums returns the Checksum of each shard.
+func Checksums(shards [][]byte) []uint32 {
+	sums := make([]uint32, len(shards))
+	for i, p := range shards {
+		sums[i] = Checksum(p)
+	}
+	return sums
+}
+
+// Verify sets to nil the shards whose Checksum is not the one in sums, so
+// that Reconstruct can repair them, and returns how many they are.
+func Verify(shards [][]byte, sums []uint32) (damaged int) {
+	for i, p := range shards {
+		if p != nil && (i >= len(sums) || Checksum(p) != sums[i]) {
+			shards[i] = nil
+			damaged++
+		}
+	}
+	return
+}
@@ -0,0 +1,193 @@
+package rs
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+func TestNew(t *testing.T) {
+	for _, e := range [][2]int{{0, 1}, {1, 0}, {200, 57}} {
+		if _, err := New(e[0], e[1]); err != ErrShards {
+			t.Error(e, err)
+		}
+	}
+	if _, err := New(200, 56); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMatrix_Invert(t *testing.T) {
+	m := vandermonde(5, 5)
+	inv, ok := m.invert()
+	if !ok {
+		t.Fatal(ok)
+	}
+	id := m.mul(inv)
+	for r := range id {
+		for c := range id[r] {
+			if v := id[r][c]; (r == c && v != 1) || (r != c && v != 0) {
+				t.Error(r, c, v)
+			}
+		}
+	}
+	if _, ok := (matrix{{1, 1}, {1, 1}}).invert(); ok {
+		t.Error(ok)
+	}
+}
+
+func TestEncoder_Reconstruct(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, dp := range [][2]int{{1, 1}, {4, 2}, {10, 4}, {17, 3}} {
+		e, err := New(dp[0], dp[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 1000)
+		r.Read(data)
+		shards := e.Split(buffers.Buffers{}.Append(data[:500], data[500:]))
+		if err := e.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+		original := make([][]byte, len(shards))
+		for i, p := range shards {
+			original[i] = append([]byte(nil), p...)
+		}
+		for k := 0; k < 20; k++ {
+			for _, i := range r.Perm(len(shards))[:e.Parity] {
+				shards[i] = nil
+			}
+			if err := e.Reconstruct(shards); err != nil {
+				t.Fatal(err)
+			}
+			for i, p := range shards {
+				if !bytes.Equal(p, original[i]) {
+					t.Error(dp, k, i)
+				}
+			}
+		}
+		if f := e.Join(shards, len(data)); !bytes.Equal(f.Bytes(), data) {
+			t.Error(dp, f.N)
+		}
+		for _, i := range r.Perm(len(shards))[:e.Parity+1] {
+			shards[i] = nil
+		}
+		if err := e.Reconstruct(shards); err != ErrTooFewShards {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEncoder_Errors(t *testing.T) {
+	e, err := New(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode([][]byte{{1}, {2}}); err != ErrShards {
+		t.Error(err)
+	}
+	if err := e.Encode([][]byte{{1}, {2, 3}, nil}); err != ErrShardSize {
+		t.Error(err)
+	}
+	if err := e.Encode([][]byte{{1}, nil, nil}); err != ErrTooFewShards {
+		t.Error(err)
+	}
+}
+
+func TestEncoder_Split(t *testing.T) {
+	e, err := New(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := e.Split(buffers.Buffers{}.Append([]byte("abcd"), []byte("efg")))
+	if l := len(shards); l != 5 {
+		t.Fatal(l)
+	}
+	for i, s := range []string{"abc", "def", "g\x00\x00"} {
+		if p := string(shards[i]); p != s {
+			t.Error(i, p)
+		}
+	}
+	if shards[3] != nil || shards[4] != nil {
+		t.Error(shards[3:])
+	}
+	if f := e.Join(shards, 7); string(f.Bytes()) != "abcdefg" {
+		t.Error(string(f.Bytes()))
+	}
+	shards = e.Split(buffers.Buffers{})
+	if l := len(shards[0]); l != 1 {
+		t.Error(l)
+	}
+}
+
+func TestEncoder_Split_Release(t *testing.T) {
+	e, err := New(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := buffers.InUse()
+	data := bytes.Repeat([]byte("abc"), buffers.SegmentSize)
+	f := buffers.Buffers{}
+	f.ReadFrom(bytes.NewReader(data))
+	shards := e.Split(f)
+	f.Release()
+	if u := buffers.InUse() - n; u != 0 {
+		t.Error(u)
+	}
+	if !bytes.Equal(bytes.Join(shards[:3], nil), data) {
+		t.Error(len(shards[0]))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	e, err := New(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	shards := e.Split(buffers.Buffers{}.Append(data))
+	if err := e.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	sums := Checksums(shards)
+	if n := Verify(shards, sums); n != 0 {
+		t.Error(n)
+	}
+	shards[1][0] ^= 1
+	shards[4][3] ^= 1
+	if n := Verify(shards, sums); n != 2 {
+		t.Error(n)
+	}
+	if shards[1] != nil || shards[4] != nil {
+		t.Error(shards)
+	}
+	if err := e.Reconstruct(shards); err != nil {
+		t.Fatal(err)
+	}
+	if n := Verify(shards, sums); n != 0 {
+		t.Error(n)
+	}
+	if f := e.Join(shards, len(data)); !bytes.Equal(f.Bytes(), data) {
+		t.Error(string(f.Bytes()))
+	}
+	if s := Checksum([]byte{0, 0}); s != 0x117697cd {
+		t.Errorf("%x", s)
+	}
+}
+
+func BenchmarkEncoder_Encode(b *testing.B) {
+	e, err := New(10, 4)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	shards := e.Split(buffers.Buffers{}.Append(data))
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Encode(shards)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/daniel-fanjul-alcuten/floc/hello"
+	"github.com/daniel-fanjul-alcuten/floc/listen"
+)
+
+type session struct {
+	mu sync.Mutex
+	id string
+}
+
+func (s *session) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// sessionHandler adds the current session id to every record, because the
+// attributes given to slog.Logger.With() are resolved only once.
+type sessionHandler struct {
+	slog.Handler
+	sess *session
+}
+
+func (h sessionHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(slog.String("session", h.sess.get()))
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h sessionHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return sessionHandler{h.Handler.WithAttrs(attrs), h.sess}
+}
+
+func (h sessionHandler) WithGroup(name string) slog.Handler {
+	return sessionHandler{h.Handler.WithGroup(name), h.sess}
+}
+
+func (s *Server) hello(id uint64, sess *session) func(context.Context, []interface{}) (interface{}, error) {
+	return func(ctx context.Context, params []interface{}) (interface{}, error) {
+		if len(params) < 1 {
+			return nil, hello.ErrSession
+		}
+		p, ok := params[0].(string)
+		if !ok || p == "" {
+			return nil, hello.ErrSession
+		}
+		h := hello.Result{Session: p, Conn: id}
+		if len(params) > 1 {
+			name, ok := params[1].(string)
+			if !ok || name == "" || s.Vault == nil {
+				return nil, hello.ErrVault
+			}
+			m, err := s.Vault(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			h.Vault = &m
+		}
+		sess.mu.Lock()
+		sess.id = p
+		sess.mu.Unlock()
+		return h, nil
+	}
+}
+
+func connAttrs(id uint64, conn net.Conn) []interface{} {
+	attrs := []interface{}{"conn", id, "peer", conn.RemoteAddr().String()}
+	if c, err := listen.PeerCred(conn); err == nil {
+		attrs = append(attrs, "pid", c.PID, "uid", c.UID, "gid", c.GID)
+	}
+	return attrs
+}
@@ -2,35 +2,153 @@ package server
 
 import (
 	"context"
+	"log/slog"
 	"net"
+	"sync/atomic"
 	"time"
 
+	"github.com/daniel-fanjul-alcuten/floc/hello"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
 	"github.com/daniel-fanjul-alcuten/floc/listen"
+	"github.com/daniel-fanjul-alcuten/floc/stats"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
 )
 
 // Server announces on an address and invokes a callback to handle the
-// connections.
+// connections. If the callback is nil, the connections are served as JSON RPC
+// v1 with the Methods.
 type Server struct {
 	Ctx     context.Context
 	Network string
 	Address string
 	Timeout time.Duration
 	Serve   func(net.Conn)
+
+	// IdleTimeout is passed to listen.Listener. The JSON RPC connections are
+	// not idle while they handle Requests or Notifications.
+	IdleTimeout time.Duration
+
+	// WriteTimeout is passed to listen.Listener.
+	WriteTimeout time.Duration
+
+	// Methods are the handlers of the JSON RPC Requests and Notifications.
+	Methods map[string]jrpc.Method
+
+	// RequestTimeout is passed to jrpc.Conn as Timeout.
+	RequestTimeout time.Duration
+
+	// PingInterval is passed to jrpc.Conn.
+	PingInterval time.Duration
+
+	// PingTimeout is passed to jrpc.Conn.
+	PingTimeout time.Duration
+
+	// Announce is used instead of net.Listen if it is not nil, like
+	// pipe.Listen.
+	Announce func(network, address string) (net.Listener, error)
+
+	// Stats, if it is not nil, keeps the metrics of the connections and the
+	// requests, and it is returned by the StatsMethod.
+	Stats *stats.Stats
+
+	// StatsAddress, if it is not empty, is the loopback TCP address where the
+	// Stats are served over HTTP in the Prometheus text format.
+	StatsAddress string
+
+	// Vault, if it is not nil, returns the Metadata of the Vault with the name
+	// for the hello.Method.
+	Vault func(ctx context.Context, name string) (vault.Metadata, error)
+
+	// Logger, if it is not nil, logs the connections with their ids and peers,
+	// and it is passed to jrpc.Conn with the session id of the hello.Method.
+	Logger *slog.Logger
 }
 
 // Listen announces on s.Network and s.Address and calls and returns
-// listen.Listener.Listen().
+// listen.Listener.Listen(). If s.StatsAddress is not empty, the Stats are
+// also served there until it returns.
 func (s *Server) Listen() error {
-	l, err := net.Listen(s.Network, s.Address)
+	announce := s.Announce
+	if announce == nil {
+		announce = net.Listen
+	}
+	l, err := announce(s.Network, s.Address)
 	if err != nil {
 		return err
 	}
 	defer l.Close()
+	if s.StatsAddress != "" {
+		closer, err := s.listenStats()
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+	}
 	ll := &listen.Listener{
-		Ctx:      s.Ctx,
-		Listener: l,
-		Timeout:  s.Timeout,
-		Serve:    s.Serve,
+		Ctx:          s.Ctx,
+		Listener:     l,
+		Timeout:      s.Timeout,
+		Serve:        s.handler(),
+		IdleTimeout:  s.IdleTimeout,
+		WriteTimeout: s.WriteTimeout,
 	}
 	return ll.Listen()
 }
+
+func (s *Server) handler() func(net.Conn) {
+	var ids uint64
+	return func(conn net.Conn) {
+		id := atomic.AddUint64(&ids, 1)
+		var logger *slog.Logger
+		if s.Logger != nil {
+			logger = s.Logger.With(connAttrs(id, conn)...)
+			logger.Info("opened")
+			defer func(start time.Time) {
+				logger.Info("closed", "duration", time.Since(start))
+			}(time.Now())
+		}
+		var busy func(bool)
+		if lc, ok := conn.(*listen.Conn); ok {
+			busy = lc.Busy
+		}
+		if s.Stats != nil {
+			var done func()
+			conn, done = s.countConn(conn)
+			defer done()
+		}
+		if s.Serve != nil {
+			s.Serve(conn)
+			return
+		}
+		s.serveJRPC(conn, id, logger, busy)
+	}
+}
+
+func (s *Server) serveJRPC(conn net.Conn, id uint64, logger *slog.Logger, busy func(bool)) {
+	sess := &session{}
+	if logger != nil {
+		logger = slog.New(sessionHandler{logger.Handler(), sess})
+	}
+	m := make(map[string]jrpc.Method, len(s.Methods)+2)
+	for k, v := range s.Methods {
+		m[k] = v
+	}
+	m[hello.Method] = s.hello(id, sess)
+	c := &jrpc.Conn{
+		Ctx:          s.Ctx,
+		Conn:         conn,
+		Methods:      m,
+		Timeout:      s.RequestTimeout,
+		PingInterval: s.PingInterval,
+		PingTimeout:  s.PingTimeout,
+		Logger:       logger,
+		Busy:         busy,
+	}
+	if s.Stats != nil {
+		m[StatsMethod] = s.statsMethod
+		c.Observe = s.observe(m)
+	}
+	if err := c.Serve(); err != nil && logger != nil {
+		logger.Warn("failed", "error", err.Error())
+	}
+}
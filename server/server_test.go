@@ -1,10 +1,25 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"log/slog"
 	"net"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/client"
+	"github.com/daniel-fanjul-alcuten/floc/hello"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/pipe"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+	"github.com/daniel-fanjul-alcuten/floc/stats"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
 )
 
 const timeout = 100 * time.Millisecond
@@ -13,8 +28,387 @@ func TestServer(t *testing.T) {
 	t.Parallel()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	s := &Server{ctx, "tcp", "127.0.0.1:0", timeout, func(net.Conn) {}}
+	s := &Server{Ctx: ctx, Network: "tcp", Address: "127.0.0.1:0", Timeout: timeout, Serve: func(net.Conn) {}}
 	if err := s.Listen(); err != context.DeadlineExceeded {
 		t.Error(err)
 	}
 }
+
+func TestServer_JRPC(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	os.Remove("./test.socket")
+	s := &Server{
+		Ctx:          ctx,
+		Network:      "unix",
+		Address:      "./test.socket",
+		Timeout:      timeout,
+		IdleTimeout:  2 * timeout,
+		WriteTimeout: timeout,
+		Methods: map[string]jrpc.Method{
+			"echo": func(ctx context.Context, params []interface{}) (interface{}, error) {
+				return params, nil
+			},
+			"sleep": func(ctx context.Context, params []interface{}) (interface{}, error) {
+				<-ctx.Done()
+				time.Sleep(timeout)
+				return nil, nil
+			},
+		},
+		RequestTimeout: timeout / 2,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Listen()
+	}()
+	var conn net.Conn
+	for i := 0; conn == nil; i++ {
+		var err error
+		if conn, err = net.DialTimeout("unix", "./test.socket", timeout); err != nil {
+			if i > 10 {
+				t.Fatal(err)
+			}
+			time.Sleep(timeout / 10)
+		}
+	}
+	defer conn.Close()
+	c := &jrpc.Conn{Ctx: ctx, Conn: conn}
+	served := make(chan error, 1)
+	go func() {
+		served <- c.Serve()
+	}()
+	if r, err := c.Call(ctx, "echo", "a"); err != nil {
+		t.Error(err)
+	} else if p, ok := r.([]interface{}); !ok || len(p) != 1 || p[0] != "a" {
+		t.Error(r)
+	}
+	if _, err := c.Call(ctx, "sleep"); err == nil {
+		t.Error(err)
+	} else if s := err.Error(); s != context.DeadlineExceeded.Error() {
+		t.Error(s)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * timeout):
+		t.Error("idle connection not closed")
+	}
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+func TestServer_IdleBusy(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*timeout)
+	defer cancel()
+	s := &Server{
+		Ctx:         ctx,
+		Network:     pipe.Network,
+		Address:     "TestServer_IdleBusy",
+		Timeout:     timeout,
+		IdleTimeout: timeout,
+		Methods: map[string]jrpc.Method{
+			"slow": func(ctx context.Context, params []interface{}) (interface{}, error) {
+				time.Sleep(3 * timeout)
+				return "done", nil
+			},
+		},
+		Announce: pipe.Listen,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Listen()
+	}()
+	c := testDial(t, ctx, "TestServer_IdleBusy")
+	if r, err := c.Call(ctx, "slow"); err != nil || r != "done" {
+		t.Error(r, err)
+	}
+	time.Sleep(3 * timeout)
+	if _, err := c.Call(ctx, "slow"); err == nil {
+		t.Error("idle connection not closed")
+	}
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+// testSession calls f with a client.Client of the Server at the pipe address,
+// retrying until it listens.
+func testSession(t *testing.T, address string, f func(*jrpc.Conn) error) {
+	c := &client.Client{
+		Network: pipe.Network,
+		Address: address,
+		Timeout: timeout,
+		Session: f,
+		Connect: pipe.DialTimeout,
+	}
+	var err error
+	for i := 0; i == 0 || err == pipe.ErrRefused && i < 10; i++ {
+		if err = c.Dial(); err == pipe.ErrRefused {
+			time.Sleep(timeout / 10)
+		}
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testDial(t *testing.T, ctx context.Context, address string) *jrpc.Conn {
+	var conn net.Conn
+	var err error
+	for i := 0; i == 0 || err == pipe.ErrRefused && i < 10; i++ {
+		if conn, err = pipe.DialTimeout(pipe.Network, address, timeout); err == pipe.ErrRefused {
+			time.Sleep(timeout / 10)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &jrpc.Conn{Ctx: ctx, Conn: conn}
+	go c.Serve()
+	return c
+}
+
+func TestServer_Pipe(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	s := &Server{
+		Ctx:     ctx,
+		Network: pipe.Network,
+		Address: "TestServer_Pipe",
+		Timeout: timeout,
+		Methods: map[string]jrpc.Method{
+			"echo": func(ctx context.Context, params []interface{}) (interface{}, error) {
+				return params[0], nil
+			},
+		},
+		Announce: pipe.Listen,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Listen()
+	}()
+	testSession(t, "TestServer_Pipe", func(c *jrpc.Conn) error {
+		r, err := c.Call(ctx, "echo", "a")
+		if err != nil {
+			return err
+		} else if r != "a" {
+			t.Error(r)
+		}
+		return nil
+	})
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+func TestServer_Stats(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	s := &Server{
+		Ctx:     ctx,
+		Network: pipe.Network,
+		Address: "TestServer_Stats",
+		Timeout: timeout,
+		Methods: map[string]jrpc.Method{
+			"echo": func(ctx context.Context, params []interface{}) (interface{}, error) {
+				return params[0], nil
+			},
+		},
+		Announce: pipe.Listen,
+		Stats:    &stats.Stats{},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Listen()
+	}()
+	testSession(t, "TestServer_Stats", func(c *jrpc.Conn) error {
+		if _, err := c.Call(ctx, "echo", "a"); err != nil {
+			t.Error(err)
+		}
+		for _, m := range []string{"random1", "random2"} {
+			if _, err := c.Call(ctx, m); err == nil {
+				t.Error(m)
+			}
+		}
+		if r, err := c.Call(ctx, StatsMethod); err != nil {
+			t.Error(err)
+		} else {
+			n := r.(map[string]interface{})
+			counters := n["counters"].(map[string]interface{})
+			if v := counters[`floc_requests_total{method="echo"}`]; v != 1.0 {
+				t.Error(v)
+			}
+			if v := counters[`floc_requests_total{method="unknown"}`]; v != 2.0 {
+				t.Error(v)
+			}
+			if v := counters[`floc_requests_total{method="random1"}`]; v != nil {
+				t.Error(v)
+			}
+			if v := counters[stats.ConnectionsTotal]; v != 1.0 {
+				t.Error(v)
+			}
+			if v := n["gauges"].(map[string]interface{})[stats.Connections]; v != 1.0 {
+				t.Error(v)
+			}
+		}
+		return nil
+	})
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+func TestServer_Hello(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	buf := &syncBuffer{}
+	s := &Server{
+		Ctx:     ctx,
+		Network: pipe.Network,
+		Address: "TestServer_Hello",
+		Timeout: timeout,
+		Methods: map[string]jrpc.Method{
+			"echo": func(ctx context.Context, params []interface{}) (interface{}, error) {
+				return params[0], nil
+			},
+		},
+		Announce: pipe.Listen,
+		Logger:   slog.New(slog.NewJSONHandler(buf, nil)),
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Listen()
+	}()
+	c := testDial(t, ctx, "TestServer_Hello")
+	if _, err := c.Call(ctx, hello.Method); err != hello.ErrSession && err.Error() != hello.ErrSession.Error() {
+		t.Error(err)
+	}
+	if r, err := c.Call(ctx, hello.Method, "s1"); err != nil {
+		t.Error(err)
+	} else if h := r.(map[string]interface{}); h["session"] != "s1" || h["conn"] != 1.0 {
+		t.Error(h)
+	}
+	if _, err := c.Call(ctx, "echo", "a"); err != nil {
+		t.Error(err)
+	}
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+	var found bool
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Error(err)
+		}
+		if m["conn"] != 1.0 || m["peer"] != "TestServer_Hello" {
+			t.Error(line)
+		}
+		if m["msg"] == "handled" && m["method"] == "echo" {
+			found = true
+			if m["session"] != "s1" || m["id"] != 2.0 || m["outcome"] != "ok" {
+				t.Error(line)
+			}
+		}
+	}
+	if !found {
+		t.Error(buf.String())
+	}
+}
+
+func TestServer_HelloVault(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*timeout)
+	defer cancel()
+	m, err := vault.NewMetadata("v1", split.Config{Algorithm: split.FastCDC}, chunk.Hasher{Algorithm: chunk.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		Ctx:     ctx,
+		Network: pipe.Network,
+		Address: "TestServer_HelloVault",
+		Timeout: timeout,
+		Vault: func(ctx context.Context, name string) (vault.Metadata, error) {
+			if name != "v1" {
+				return vault.Metadata{}, hello.ErrVault
+			}
+			return m, nil
+		},
+		Announce: pipe.Listen,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Listen()
+	}()
+	c := testDial(t, ctx, "TestServer_HelloVault")
+	if _, err := c.Call(ctx, hello.Method, "s1", "v2"); err == nil || err.Error() != hello.ErrVault.Error() {
+		t.Error(err)
+	}
+	if r, err := c.Call(ctx, hello.Method, "s1", "v1"); err != nil {
+		t.Error(err)
+	} else if v := r.(map[string]interface{})["vault"].(map[string]interface{}); v["name"] != "v1" || v["fingerprint"] != m.Fingerprint || v["chunkId"] != "sha256" {
+		t.Error(v)
+	}
+	if r, err := c.Call(ctx, hello.Method, "s1"); err != nil {
+		t.Error(err)
+	} else if v, ok := r.(map[string]interface{})["vault"]; ok {
+		t.Error(v)
+	}
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServer_StatsAddress(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s := &Server{
+		Ctx:          ctx,
+		Network:      pipe.Network,
+		Address:      "TestServer_StatsAddress",
+		Timeout:      timeout,
+		Announce:     pipe.Listen,
+		StatsAddress: "0.0.0.0:0",
+		Stats:        &stats.Stats{},
+	}
+	if err := s.Listen(); err == nil {
+		t.Error(err)
+	} else if s := err.Error(); s != `server: stats address "0.0.0.0:0" is not loopback` {
+		t.Error(s)
+	}
+}
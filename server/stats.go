@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/stats"
+)
+
+// StatsMethod is the name of the JSON RPC method that returns the
+// stats.Snapshot of Server.Stats.
+const StatsMethod = "Server.Stats"
+
+type countingConn struct {
+	net.Conn
+	received, sent *stats.Counter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.received.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.sent.Add(int64(n))
+	return n, err
+}
+
+func (s *Server) countConn(conn net.Conn) (net.Conn, func()) {
+	s.Stats.Counter(stats.ConnectionsTotal).Add(1)
+	current := s.Stats.Gauge(stats.Connections)
+	current.Add(1)
+	received := s.Stats.Counter(stats.ReceivedBytes)
+	sent := s.Stats.Counter(stats.SentBytes)
+	return &countingConn{conn, received, sent}, func() {
+		current.Add(-1)
+	}
+}
+
+func (s *Server) statsMethod(ctx context.Context, params []interface{}) (interface{}, error) {
+	return s.Stats.Snapshot(), nil
+}
+
+// unknownMethod labels the requests of the methods that are not served, so
+// that clients cannot add a metric with each name they send.
+const unknownMethod = "unknown"
+
+// observe returns a jrpc.Conn.Observe that labels the requests with the
+// methods of m.
+func (s *Server) observe(m map[string]jrpc.Method) func(string, time.Duration, error) {
+	return func(method string, d time.Duration, err error) {
+		if _, ok := m[method]; !ok {
+			method = unknownMethod
+		}
+		s.observeMethod(method, d, err)
+	}
+}
+
+func (s *Server) observeMethod(method string, d time.Duration, err error) {
+	s.Stats.Counter(stats.Key(stats.Requests, "method", method)).Add(1)
+	if err != nil {
+		s.Stats.Counter(stats.Key(stats.RequestErrors, "method", method)).Add(1)
+	}
+	s.Stats.Histogram(stats.Key(stats.RequestSeconds, "method", method), stats.SecondsBounds).Observe(d.Seconds())
+}
+
+func (s *Server) listenStats() (io.Closer, error) {
+	if s.Stats == nil {
+		return nil, fmt.Errorf("server: stats address %q without stats", s.StatsAddress)
+	}
+	host, _, err := net.SplitHostPort(s.StatsAddress)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("server: stats address %q is not loopback", s.StatsAddress)
+	}
+	l, err := net.Listen("tcp", s.StatsAddress)
+	if err != nil {
+		return nil, err
+	}
+	hs := &http.Server{Handler: s.Stats}
+	go hs.Serve(l)
+	return hs, nil
+}
@@ -0,0 +1,99 @@
+package split
+
+import (
+	"io"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+// Chunk is a piece of a stream returned by Chunker.Next.
+type Chunk struct {
+
+	// Offset is the position of the first byte in the stream.
+	Offset int64
+
+	// Data are the bytes of the Chunk. Data.N is its length.
+	Data buffers.Buffers
+
+	// ID is the chunk.ID of Data.
+	ID chunk.ID
+}
+
+// Release frees the memory of c.Data, which may not be used afterwards. The
+// other Chunks that share its segments are not affected.
+func (c Chunk) Release() {
+	c.Data.Release()
+}
+
+// Chunker splits the bytes read from R with Split into Chunks. The boundaries
+// are the same that Split would find in the whole stream in memory, regardless
+// of how the reads of R are sized, and at most Split.MaxLen() bytes are
+// buffered.
+type Chunker struct {
+	Split Splitter
+
+	// Hasher computes the ID of the Chunks. It is chunk.SHA256 if its
+	// Algorithm is zero.
+	Hasher chunk.Hasher
+
+	R io.Reader
+
+	buf buffers.Buffers
+	off int64
+	eof bool
+}
+
+func (c *Chunker) fill() error {
+	max := c.Split.MaxLen()
+	for !c.eof && c.buf.N < max {
+		need := int64(max - c.buf.N)
+		n, err := c.buf.ReadFrom(io.LimitReader(c.R, need))
+		if err != nil {
+			return err
+		}
+		if n < need {
+			c.eof = true
+		}
+	}
+	return nil
+}
+
+// Next returns the next Chunk, which must be released after use, or io.EOF at
+// the end of R. Reading R stops at the first error, which is returned.
+func (c *Chunker) Next() (ch Chunk, err error) {
+	if ch, err = c.Cut(); err != nil {
+		return
+	}
+	h := c.Hasher
+	if h.Algorithm == 0 {
+		h.Algorithm = chunk.SHA256
+	}
+	if ch.ID, err = h.Sum(ch.Data); err != nil {
+		ch.Release()
+		ch = Chunk{}
+	}
+	return
+}
+
+// Cut is like Next but the ID of the Chunk is not computed, so that it can be
+// done by another goroutine.
+func (c *Chunker) Cut() (ch Chunk, err error) {
+	if err = c.fill(); err != nil {
+		return
+	}
+	if c.buf.N == 0 {
+		return ch, io.EOF
+	}
+	g, r := c.Split.Split(c.buf)
+	ch.Offset, ch.Data = c.off, g
+	c.off, c.buf = c.off+int64(g.N), r
+	return
+}
+
+// Close frees the memory of the bytes buffered by c but not returned in a
+// Chunk yet.
+func (c *Chunker) Close() {
+	c.buf.Release()
+	c.buf = buffers.Buffers{}
+}
@@ -0,0 +1,114 @@
+package split
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+)
+
+func testChunker(t *testing.T, r io.Reader, s Split, data []byte) {
+	inUse := buffers.InUse()
+	var want []int
+	for f := (buffers.Buffers{}).Append(data); f.N > 0; {
+		var g buffers.Buffers
+		g, f = s.Split(f)
+		want = append(want, g.N)
+	}
+	c := &Chunker{Split: &s, R: r}
+	var chunks []Chunk
+	for {
+		ch, err := c.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, ch)
+	}
+	if len(chunks) != len(want) {
+		t.Fatal(len(chunks), len(want))
+	}
+	off := 0
+	for i, ch := range chunks {
+		if ch.Offset != int64(off) {
+			t.Error(i, ch.Offset)
+		}
+		if ch.Data.N != want[i] {
+			t.Error(i, ch.Data.N, want[i])
+		}
+		if !bytes.Equal(ch.Data.Bytes(), data[off:off+want[i]]) {
+			t.Error(i)
+		}
+		if id, _ := (chunk.Hasher{Algorithm: chunk.SHA256}).Sum(ch.Data); id != ch.ID {
+			t.Error(i, ch.ID)
+		}
+		off += want[i]
+	}
+	for _, ch := range chunks {
+		ch.Release()
+	}
+	c.Close()
+	if n := buffers.InUse() - inUse; n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestChunker(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	s := testSplit
+	s.Max = 1 << 14
+	s.Mask, s.Cond = 1<<10-1, 1<<10-1
+	testChunker(t, bytes.NewReader(data), s, data)
+	testChunker(t, iotest.HalfReader(bytes.NewReader(data)), s, data)
+	testChunker(t, iotest.OneByteReader(bytes.NewReader(data[:1<<16])), s, data[:1<<16])
+	s.Max = 1 << 17
+	testChunker(t, iotest.DataErrReader(bytes.NewReader(data)), s, data)
+}
+
+func TestChunker_Empty(t *testing.T) {
+	s := testSplit
+	c := &Chunker{Split: &s, R: bytes.NewReader(nil)}
+	if _, err := c.Next(); err != io.EOF {
+		t.Error(err)
+	}
+}
+
+func TestChunker_Error(t *testing.T) {
+	s := testSplit
+	c := &Chunker{Split: &s, R: iotest.TimeoutReader(bytes.NewReader(make([]byte, 10)))}
+	if _, err := c.Next(); err != iotest.ErrTimeout {
+		t.Error(err)
+	}
+	c.Close()
+}
+
+func TestChunker_Hasher(t *testing.T) {
+	s := testSplit
+	c := &Chunker{Split: &s, Hasher: chunk.Hasher{Algorithm: chunk.HMACSHA256}, R: bytes.NewReader([]byte("a"))}
+	if _, err := c.Next(); err != chunk.ErrKey {
+		t.Error(err)
+	}
+	c.Close()
+}
+
+func TestChunker_Cut(t *testing.T) {
+	s := testSplit
+	c := &Chunker{Split: &s, R: bytes.NewReader([]byte("abc"))}
+	ch, err := c.Cut()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Data.N != 3 || !ch.ID.IsZero() {
+		t.Error(ch.Data.N, ch.ID)
+	}
+	ch.Release()
+	if _, err := c.Cut(); err != io.EOF {
+		t.Error(err)
+	}
+}
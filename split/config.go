@@ -0,0 +1,111 @@
+package split
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+// The names of the algorithms of a Config.
+const (
+	Rolling = "rolling"
+	FastCDC = "fastcdc"
+)
+
+var (
+	// ErrAlgorithm is returned by Config.Splitter for an unknown algorithm.
+	ErrAlgorithm = errors.New("split: unknown algorithm")
+
+	// ErrConfig is returned by Config.Splitter for invalid parameters.
+	ErrConfig = errors.New("split: invalid parameters")
+)
+
+// Config selects a Splitter by the name of its algorithm and its parameters,
+// so that each Vault can choose one. The parameters that the algorithm does
+// not use must be zero.
+type Config struct {
+	Algorithm string `json:"algorithm"`
+	Min       int    `json:"min,omitempty"`
+	Max       int    `json:"max,omitempty"`
+	Mask      uint32 `json:"mask,omitempty"`
+	Cond      uint32 `json:"cond,omitempty"`
+	Window    int    `json:"window,omitempty"`
+	Avg       int    `json:"avg,omitempty"`
+	Level     int    `json:"level,omitempty"`
+}
+
+// Splitter returns a new Splitter of the Config: a *Split for Rolling and a
+// *Gear for FastCDC. After the defaults are applied, Min must be less than
+// Max, Avg must be between them, Level must be less than log2(Avg), and Cond
+// must have no bits outside Mask, or else no Split would ever match it.
+func (c Config) Splitter() (Splitter, error) {
+	if c.Min < 0 || c.Max < 0 || c.Window < 0 || c.Avg < 0 || c.Level < 0 {
+		return nil, ErrConfig
+	}
+	switch c.Algorithm {
+	case Rolling:
+		if c.Avg != 0 || c.Level != 0 {
+			return nil, ErrConfig
+		}
+		s := &Split{Min: c.Min, Max: c.Max, Mask: c.Mask, Cond: c.Cond, Window: c.Window}
+		d := *s
+		d.Reset()
+		if d.Min >= d.Max || d.Cond&^d.Mask != 0 {
+			return nil, ErrConfig
+		}
+		return s, nil
+	case FastCDC:
+		if c.Mask != 0 || c.Cond != 0 || c.Window != 0 {
+			return nil, ErrConfig
+		}
+		if c.Avg&(c.Avg-1) != 0 {
+			return nil, ErrConfig
+		}
+		g := &Gear{Min: c.Min, Avg: c.Avg, Max: c.Max, Level: c.Level}
+		d := *g
+		d.Reset()
+		bits := 0
+		for 1<<uint(bits) < d.Avg {
+			bits++
+		}
+		if d.Min >= d.Max || d.Min > d.Avg || d.Avg > d.Max || d.Level >= bits || bits+d.Level > 64 {
+			return nil, ErrConfig
+		}
+		return g, nil
+	}
+	return nil, ErrAlgorithm
+}
+
+// Normalize returns c with the zero parameters replaced by the default values
+// that its Splitter would use, so that equivalent Configs are equal.
+func (c Config) Normalize() (Config, error) {
+	s, err := c.Splitter()
+	if err != nil {
+		return c, err
+	}
+	switch s := s.(type) {
+	case *Split:
+		s.Reset()
+		return Config{Algorithm: Rolling, Min: s.Min, Max: s.Max, Mask: s.Mask, Cond: s.Cond, Window: s.Window}, nil
+	case *Gear:
+		s.Reset()
+		return Config{Algorithm: FastCDC, Min: s.Min, Avg: s.Avg, Max: s.Max, Level: s.Level}, nil
+	}
+	return c, ErrAlgorithm
+}
+
+// Fingerprint returns a short hexadecimal digest of the normalized c, which is
+// equal for two Configs if and only if they find the same boundaries.
+func (c Config) Fingerprint() (string, error) {
+	n, err := c.Normalize()
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(n)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(p)
+	return hex.EncodeToString(sum[:8]), nil
+}
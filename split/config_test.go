@@ -0,0 +1,109 @@
+package split
+
+import "testing"
+
+func TestConfig_Splitter(t *testing.T) {
+	type entry struct {
+		given Config
+		err   error
+	}
+	for i, e := range []entry{
+		// 0
+		{Config{Algorithm: Rolling}, nil},
+		// 1
+		{Config{Algorithm: Rolling, Min: 1, Max: 10, Mask: 7, Cond: 7, Window: 64}, nil},
+		// 2
+		{Config{Algorithm: FastCDC}, nil},
+		// 3
+		{Config{Algorithm: FastCDC, Min: 1, Avg: 8, Max: 10, Level: 1}, nil},
+		// 4
+		{Config{Algorithm: "md5"}, ErrAlgorithm},
+		// 5
+		{Config{Algorithm: Rolling, Avg: 8}, ErrConfig},
+		// 6
+		{Config{Algorithm: FastCDC, Window: 8}, ErrConfig},
+		// 7
+		{Config{Algorithm: FastCDC, Avg: 7}, ErrConfig},
+		// 8
+		{Config{Algorithm: Rolling, Min: 2, Max: 1}, ErrConfig},
+		// 9
+		{Config{Algorithm: Rolling, Min: -1}, ErrConfig},
+		// 10
+		{Config{Algorithm: Rolling, Min: 10, Max: 10}, ErrConfig},
+		// 11
+		{Config{Algorithm: Rolling, Min: DefaultMax}, ErrConfig},
+		// 12
+		{Config{Algorithm: FastCDC, Min: 8, Avg: 8, Max: 8}, ErrConfig},
+		// 13
+		{Config{Algorithm: FastCDC, Min: 1 << 17}, ErrConfig},
+		// 14
+		{Config{Algorithm: FastCDC, Min: 16, Avg: 8, Max: 32}, ErrConfig},
+		// 15
+		{Config{Algorithm: FastCDC, Min: 1, Avg: 16, Max: 8}, ErrConfig},
+		// 16
+		{Config{Algorithm: FastCDC, Max: 1 << 10}, ErrConfig},
+		// 17
+		{Config{Algorithm: FastCDC, Min: 1, Avg: 8, Max: 16, Level: 3}, ErrConfig},
+		// 18
+		{Config{Algorithm: FastCDC, Min: 1, Avg: 4, Max: 8}, ErrConfig},
+		// 19
+		{Config{Algorithm: Rolling, Mask: 7, Cond: 8}, ErrConfig},
+		// 20
+		{Config{Algorithm: Rolling, Mask: 0xff}, ErrConfig},
+		// 21
+		{Config{Algorithm: Rolling, Mask: 1<<13 - 1}, nil},
+	} {
+		s, err := e.given.Splitter()
+		if err != e.err {
+			t.Errorf("%v: %v", i, err)
+		} else if err == nil && s == nil {
+			t.Errorf("%v: nil", i)
+		}
+	}
+	s, _ := Config{Algorithm: FastCDC, Min: 1, Avg: 8, Max: 10, Level: 1}.Splitter()
+	if g := s.(*Gear); *g != (Gear{1, 8, 10, 1}) {
+		t.Error(g)
+	}
+	s, _ = Config{Algorithm: Rolling, Max: 10}.Splitter()
+	if m := s.MaxLen(); m != 10 {
+		t.Error(m)
+	}
+}
+
+func TestConfig_Fingerprint(t *testing.T) {
+	f1, err := Config{Algorithm: Rolling}.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := Config{Algorithm: Rolling, Max: DefaultMax, Mask: 1<<12 - 1, Cond: 1<<12 - 1, Window: 1 << 13}.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f1 != f2 {
+		t.Error(f1, f2)
+	}
+	if l := len(f1); l != 16 {
+		t.Error(l)
+	}
+	f3, err := Config{Algorithm: Rolling, Window: 64}.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f1 == f3 {
+		t.Error(f3)
+	}
+	f4, err := Config{Algorithm: FastCDC}.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f5, err := Config{Algorithm: FastCDC, Min: 1 << 11, Avg: 1 << 13, Max: 1 << 16, Level: 2}.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f4 != f5 || f4 == f1 {
+		t.Error(f4, f5)
+	}
+	if _, err := (Config{}).Fingerprint(); err != ErrAlgorithm {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,144 @@
+package split
+
+import "github.com/daniel-fanjul-alcuten/floc/buffers"
+
+// Splitter finds the boundaries of the chunks of a stream.
+type Splitter interface {
+
+	// Split splits the Buffers f into the Buffers g and r such that the
+	// concatenation of g and r is equal to f and g ends at the first boundary
+	// found, or else g is f. The references of f to the segments of the
+	// buffers pool are moved to g and r, see Buffers.SplitAt.
+	Split(f buffers.Buffers) (g buffers.Buffers, r buffers.Buffers)
+
+	// MaxLen is the maximum length of g.
+	MaxLen() int
+
+	// Scan appends to cuts the offsets in f of the ends of the chunks that
+	// repeated calls to Split would return, except the last one when it does
+	// not end at a boundary, and returns cuts. It does not allocate other
+	// memory than cuts.
+	Scan(f buffers.Buffers, cuts []int) []int
+}
+
+// MaxLen returns h.Max or its default value.
+func (h *Split) MaxLen() int {
+	if h.Max == 0 {
+		return DefaultMax
+	}
+	return h.Max
+}
+
+// gearTable maps each byte to a pseudorandom value of the Gear hash. It is
+// generated with SplitMix64 from a fixed seed and it must never change,
+// because it determines all boundaries.
+var gearTable [256]uint64
+
+func init() {
+	x := uint64(0x666c6f63)
+	for i := range gearTable {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gearTable[i] = z ^ (z >> 31)
+	}
+}
+
+// Gear is the configuration of the FastCDC algorithm: a Gear rolling hash
+// with normalized chunking, which makes the lengths of the chunks concentrate
+// around Avg and finds boundaries also in low entropy data.
+type Gear struct {
+
+	// Min is the minimum allowed length of the Split. The first Min bytes are
+	// skipped without hashing.
+	Min int
+
+	// Avg is the expected length of the Split. It must be a power of two.
+	Avg int
+
+	// Max is the maximum allowed length of the Split.
+	Max int
+
+	// Level is the number of bits by which the mask is harder before Avg and
+	// easier after Avg. It must be less than log2(Avg).
+	Level int
+}
+
+// Reset sets the configuration fields that are zero values to proper default
+// values when these zero values are not valid values.
+func (h *Gear) Reset() {
+	if h.Avg == 0 {
+		h.Avg = 1 << 13
+	}
+	if h.Min == 0 {
+		h.Min = h.Avg / 4
+	}
+	if h.Max == 0 {
+		h.Max = h.Avg * 8
+	}
+	if h.Level == 0 {
+		h.Level = 2
+	}
+}
+
+// MaxLen returns h.Max or its default value.
+func (h *Gear) MaxLen() int {
+	if h.Max == 0 {
+		g := *h
+		g.Reset()
+		return g.Max
+	}
+	return h.Max
+}
+
+func gearMask(bits int) uint64 {
+	if bits <= 0 {
+		return 0
+	}
+	if bits >= 64 {
+		return ^uint64(0)
+	}
+	return ^uint64(0) << (64 - uint(bits))
+}
+
+// Split splits the Buffers f into the Buffers g and r such that the
+// concatenation of g and r is equal to f, g.N >= h.Min, g.N <= h.Max, and the
+// Gear hash of g masked by the hard mask before h.Avg or by the easy mask
+// after it is zero, or else g.N == h.Max. The slices of f are shared with g
+// and r. h.Reset() is invoked before anything else.
+func (h *Gear) Split(f buffers.Buffers) (g buffers.Buffers, r buffers.Buffers) {
+	h.Reset()
+	if f.N <= h.Min {
+		return f, r
+	}
+	bits := 0
+	for 1<<uint(bits) < h.Avg {
+		bits++
+	}
+	maskS, maskL := gearMask(bits+h.Level), gearMask(bits-h.Level)
+	var acc uint64
+	i := 0
+	for _, p := range f.S {
+		j := 0
+		if i < h.Min {
+			if i+len(p) <= h.Min {
+				i += len(p)
+				continue
+			}
+			j, i = h.Min-i, h.Min
+		}
+		for ; j < len(p); j++ {
+			acc = acc<<1 + gearTable[p[j]]
+			i++
+			mask := maskL
+			if i < h.Avg {
+				mask = maskS
+			}
+			if acc&mask == 0 || i >= h.Max {
+				return f.SplitAt(i)
+			}
+		}
+	}
+	return f, r
+}
@@ -0,0 +1,194 @@
+package split
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+func TestGear_Defaults(t *testing.T) {
+	g := Gear{}
+	if m := g.MaxLen(); m != 1<<16 {
+		t.Error(m)
+	}
+	g.Reset()
+	if g != (Gear{1 << 11, 1 << 13, 1 << 16, 2}) {
+		t.Error(g)
+	}
+}
+
+func TestGear_Split(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	h := Gear{Min: 1 << 10, Avg: 1 << 12, Max: 1 << 14}
+	f := buffers.Buffers{}.Append(data[:1000], data[1000:5000], data[5000:])
+	for f.N > 0 {
+		g, r := h.Split(f)
+		if g.N+r.N != f.N {
+			t.Fatal(g.N, r.N)
+		}
+		if g.N < h.Min && r.N > 0 {
+			t.Error(g.N)
+		}
+		if g.N > h.Max {
+			t.Error(g.N)
+		}
+		g2, _ := h.Split(buffers.Buffers{}.Append(f.Bytes()))
+		if g2.N != g.N {
+			t.Error(g.N, g2.N)
+		}
+		f = r
+	}
+	if g, r := h.Split(buffers.Buffers{}.Append(data[:10])); g.N != 10 || r.N != 0 {
+		t.Error(g.N, r.N)
+	}
+}
+
+type chunkStats struct {
+	n          int
+	mean, sd   float64
+	dedup      float64
+	minN, maxN int
+	atMax      int
+}
+
+func splitAll(s Splitter, data []byte) [][]byte {
+	var chunks [][]byte
+	for f := (buffers.Buffers{}).Append(data); f.N > 0; {
+		var g buffers.Buffers
+		g, f = s.Split(f)
+		chunks = append(chunks, g.Bytes())
+	}
+	return chunks
+}
+
+// measure splits base and then edited, and reports the distribution of the
+// lengths of the chunks of edited and the fraction of its bytes that are
+// deduplicated against base.
+func measure(s Splitter, base, edited []byte) (c chunkStats) {
+	seen := make(map[[32]byte]bool)
+	for _, p := range splitAll(s, base) {
+		seen[sha256.Sum256(p)] = true
+	}
+	chunks := splitAll(s, edited)
+	c.n, c.minN = len(chunks), math.MaxInt32
+	dup := 0
+	for _, p := range chunks {
+		c.mean += float64(len(p))
+		if seen[sha256.Sum256(p)] {
+			dup += len(p)
+		}
+		if len(p) < c.minN {
+			c.minN = len(p)
+		}
+		if len(p) > c.maxN {
+			c.maxN = len(p)
+		}
+		if len(p) == s.MaxLen() {
+			c.atMax++
+		}
+	}
+	c.mean /= float64(len(chunks))
+	for _, p := range chunks {
+		d := float64(len(p)) - c.mean
+		c.sd += d * d
+	}
+	c.sd = math.Sqrt(c.sd / float64(len(chunks)))
+	c.dedup = float64(dup) / float64(len(edited))
+	return
+}
+
+// edit returns a copy of data with some insertions and deletions that shift
+// the rest of the data.
+func edit(r *rand.Rand, data []byte) []byte {
+	out := append([]byte(nil), data...)
+	for i := 0; i < 8; i++ {
+		at := r.Intn(len(out))
+		if i%2 == 0 {
+			ins := make([]byte, 1+r.Intn(100))
+			r.Read(ins)
+			out = append(out[:at], append(ins, out[at:]...)...)
+		} else {
+			n := 1 + r.Intn(100)
+			if at+n > len(out) {
+				n = len(out) - at
+			}
+			out = append(out[:at], out[at+n:]...)
+		}
+	}
+	return out
+}
+
+func text(r *rand.Rand, n int) []byte {
+	words := []string{"the ", "floc ", "vault ", "archive ", "chunk ", "a ", "backup ", "of ", "data ", "\n"}
+	var b bytes.Buffer
+	for b.Len() < n {
+		b.WriteString(words[r.Intn(len(words))])
+	}
+	return b.Bytes()[:n]
+}
+
+func TestGear_Compare(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 8<<20)
+	io.ReadFull(r, random)
+	rolling := &Split{Max: 1 << 16, Mask: 1<<13 - 1, Cond: 1<<13 - 1, Window: 1 << 6}
+	gear := &Gear{Min: 1 << 11, Avg: 1 << 13, Max: 1 << 16}
+	for _, e := range []struct {
+		name string
+		data []byte
+	}{
+		{"random", random},
+		{"text", text(r, 8<<20)},
+	} {
+		edited := edit(r, e.data)
+		rs := measure(rolling, e.data, edited)
+		gs := measure(gear, e.data, edited)
+		t.Logf("%v rolling: %+v", e.name, rs)
+		t.Logf("%v gear: %+v", e.name, gs)
+		if gs.dedup < 0.95 {
+			t.Errorf("%v: gear dedup %v", e.name, gs.dedup)
+		}
+		if gs.dedup < rs.dedup-0.01 {
+			t.Errorf("%v: gear dedup %v < rolling dedup %v", e.name, gs.dedup, rs.dedup)
+		}
+		if rs.atMax*2 < rs.n && gs.sd/gs.mean >= rs.sd/rs.mean {
+			t.Errorf("%v: gear cv %v >= rolling cv %v", e.name, gs.sd/gs.mean, rs.sd/rs.mean)
+		}
+		if gs.mean < float64(gear.Min) || gs.mean > float64(2*gear.Avg) {
+			t.Errorf("%v: gear mean %v", e.name, gs.mean)
+		}
+		if gs.atMax*100 > gs.n {
+			t.Errorf("%v: gear %v of %v chunks at max", e.name, gs.atMax, gs.n)
+		}
+	}
+}
+
+func BenchmarkGear(t *testing.B) {
+	if benchmarkSplitBuffer == nil {
+		r := rand.New(rand.NewSource(1))
+		p := make([]byte, 1024*1024*1024)
+		if _, err := io.ReadFull(r, p); err != nil {
+			t.Fatal(err)
+		}
+		benchmarkSplitBuffer = p
+	}
+	s := Gear{Max: 1 << 20}
+	t.ResetTimer()
+	for n := t.N; n > 0; {
+		p := benchmarkSplitBuffer
+		if len(p) > n {
+			p = p[:n]
+		}
+		n -= len(p)
+		for f := (buffers.Buffers{}).Append(p); f.N > 0; {
+			_, f = s.Split(f)
+		}
+	}
+	t.SetBytes(int64(t.N))
+}
@@ -0,0 +1,206 @@
+package split
+
+import (
+	"io"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+// scanner is the state of a scan that is carried across the slices of a
+// stream.
+type scanner interface {
+
+	// scan appends to cuts the offsets in p of the ends of the chunks, going on
+	// from the previous slices, and returns cuts.
+	scan(p []byte, cuts []int) []int
+}
+
+// resumable is implemented by the Splitters of this package, whose scans
+// ScanReader carries across its reads.
+type resumable interface {
+
+	// scanner returns the state of a new scan.
+	scanner() scanner
+}
+
+// scanAll implements Splitter.Scan with s.
+func scanAll(s scanner, f buffers.Buffers, cuts []int) []int {
+	off := 0
+	for _, p := range f.S {
+		k := len(cuts)
+		cuts = s.scan(p, cuts)
+		for ; k < len(cuts); k++ {
+			cuts[k] += off
+		}
+		off += len(p)
+	}
+	return cuts
+}
+
+type rollingScanner struct {
+	h    *Split
+	n, l int
+	acc  uint32
+}
+
+func (h *Split) scanner() scanner {
+	h.Reset()
+	return &rollingScanner{h: h}
+}
+
+func (s *rollingScanner) scan(p []byte, cuts []int) []int {
+	h, n, l, acc := s.h, s.n, s.l, s.acc
+	for j, b := range p {
+		acc += uint32(b) - uint32(h.Ring[l])
+		n++
+		if (n >= h.Min && acc&h.Mask == h.Cond) || n >= h.Max {
+			cuts = append(cuts, j+1)
+			for k := range h.Ring {
+				h.Ring[k] = 0
+			}
+			n, l, acc = 0, 0, 0
+			continue
+		}
+		h.Ring[l], l = b, l+1
+		if l == len(h.Ring) {
+			l = 0
+		}
+	}
+	s.n, s.l, s.acc = n, l, acc
+	return cuts
+}
+
+// Scan implements Splitter.Scan with the same boundaries as h.Split.
+func (h *Split) Scan(f buffers.Buffers, cuts []int) []int {
+	h.Reset()
+	s := rollingScanner{h: h}
+	return scanAll(&s, f, cuts)
+}
+
+type gearScanner struct {
+	h            *Gear
+	maskS, maskL uint64
+	i            int
+	acc          uint64
+}
+
+func newGearScanner(h *Gear) gearScanner {
+	h.Reset()
+	bits := 0
+	for 1<<uint(bits) < h.Avg {
+		bits++
+	}
+	return gearScanner{h: h, maskS: gearMask(bits + h.Level), maskL: gearMask(bits - h.Level)}
+}
+
+func (h *Gear) scanner() scanner {
+	s := newGearScanner(h)
+	return &s
+}
+
+func (s *gearScanner) scan(p []byte, cuts []int) []int {
+	h, i, acc := s.h, s.i, s.acc
+	for j := 0; j < len(p); {
+		if i < h.Min {
+			k := h.Min - i
+			if k > len(p)-j {
+				k = len(p) - j
+			}
+			j, i = j+k, i+k
+			continue
+		}
+		acc = acc<<1 + gearTable[p[j]]
+		j, i = j+1, i+1
+		mask := s.maskL
+		if i < h.Avg {
+			mask = s.maskS
+		}
+		if acc&mask == 0 || i >= h.Max {
+			cuts = append(cuts, j)
+			i, acc = 0, 0
+		}
+	}
+	s.i, s.acc = i, acc
+	return cuts
+}
+
+// Scan implements Splitter.Scan with the same boundaries as h.Split.
+func (h *Gear) Scan(f buffers.Buffers, cuts []int) []int {
+	s := newGearScanner(h)
+	return scanAll(&s, f, cuts)
+}
+
+// ScanReader reads r until io.EOF and calls cut with the offset in the stream
+// of the end of each chunk, including the last one, with the same boundaries
+// as a Chunker. The bytes are read into a single buffer and the state of the
+// Splitters of this package is carried across the reads, so that each byte is
+// scanned once. Other Splitters split the bytes read after the last cut. It
+// returns the first error of r or cut.
+func ScanReader(s Splitter, r io.Reader, cut func(off int64) error) error {
+	rs, ok := s.(resumable)
+	if !ok {
+		return splitReader(s, r, cut)
+	}
+	var (
+		p          = make([]byte, scanSize)
+		sc         = rs.scanner()
+		cuts       []int
+		base, last int64
+	)
+	for {
+		n, err := io.ReadFull(r, p)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		cuts = sc.scan(p[:n], cuts[:0])
+		for _, c := range cuts {
+			last = base + int64(c)
+			if err := cut(last); err != nil {
+				return err
+			}
+		}
+		base += int64(n)
+		if err != nil {
+			break
+		}
+	}
+	if last < base {
+		return cut(base)
+	}
+	return nil
+}
+
+// scanSize is the length of the reads of ScanReader.
+const scanSize = 1 << 20
+
+// splitReader implements ScanReader with s.Split, which splits again the
+// bytes after the last boundary when more are read.
+func splitReader(s Splitter, r io.Reader, cut func(off int64) error) error {
+	var (
+		f   buffers.Buffers
+		off int64
+	)
+	for {
+		p := make([]byte, scanSize)
+		n, err := io.ReadFull(r, p)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		eof := err != nil
+		f = f.Append(p[:n])
+		for f.N > 0 {
+			g, rest := s.Split(f)
+			if g.N == f.N && !eof {
+				break
+			}
+			off += int64(g.N)
+			if err := cut(off); err != nil {
+				return err
+			}
+			f = rest
+		}
+		if eof {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package split
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/daniel-fanjul-alcuten/floc/buffers"
+)
+
+// segmented returns data in slices of random lengths.
+func segmented(r *rand.Rand, data []byte) (f buffers.Buffers) {
+	for len(data) > 0 {
+		n := r.Intn(1<<14) + 1
+		if n > len(data) {
+			n = len(data)
+		}
+		f, data = f.Append(data[:n]), data[n:]
+	}
+	return
+}
+
+// other hides the scanner of a Splitter, like the Splitters of other
+// packages.
+type other struct {
+	Splitter
+}
+
+func TestScan(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 3<<20)
+	r.Read(data)
+	low := text(r, 1<<19)
+	s := testSplit
+	s.Max = 1 << 14
+	s.Mask, s.Cond = 1<<10-1, 1<<10-1
+	for i, c := range []struct {
+		s    Splitter
+		data []byte
+	}{
+		{&s, data}, // 0
+		{&Split{Min: 100, Max: 5000, Window: 64}, data},   // 1
+		{&Gear{Avg: 1 << 12}, data},                       // 2
+		{&Gear{Avg: 1 << 10, Min: 1, Level: 3}, low},      // 3
+		{&Gear{Avg: 1 << 12, Max: 1 << 12}, data[:70000]}, // 4
+		{&Gear{}, data[:100]},                             // 5
+		{&Split{}, make([]byte, 5<<20)},                   // 6
+		{&Gear{Max: 1<<20 + 1}, make([]byte, 3<<20)},      // 7
+		{other{&s}, data},                                 // 8
+		{other{&Gear{Avg: 1 << 12}}, data},                // 9
+	} {
+		var want []int
+		off := 0
+		for _, p := range splitAll(c.s, c.data) {
+			off += len(p)
+			want = append(want, off)
+		}
+		cuts := c.s.Scan(segmented(r, c.data), nil)
+		if l := len(want) - len(cuts); l != 0 && l != 1 {
+			t.Fatal(i, len(cuts), len(want))
+		}
+		if fmt.Sprint(cuts) != fmt.Sprint(want[:len(cuts)]) {
+			t.Error(i, cuts)
+		}
+		var got []int
+		if err := ScanReader(c.s, iotest.HalfReader(bytes.NewReader(c.data)), func(off int64) error {
+			got = append(got, int(off))
+			return nil
+		}); err != nil {
+			t.Error(i, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Error(i, len(got), len(want))
+		}
+	}
+}
+
+func TestScanReader_Error(t *testing.T) {
+	s := &Gear{Avg: 1 << 8}
+	data := make([]byte, 1<<16)
+	rand.New(rand.NewSource(1)).Read(data)
+	if err := ScanReader(s, iotest.TimeoutReader(bytes.NewReader(data)), func(int64) error {
+		return nil
+	}); err != iotest.ErrTimeout {
+		t.Error(err)
+	}
+	stop := errors.New("stop")
+	n := 0
+	if err := ScanReader(s, bytes.NewReader(data), func(int64) error {
+		n++
+		return stop
+	}); err != stop || n != 1 {
+		t.Error(err, n)
+	}
+	if err := ScanReader(s, bytes.NewReader(nil), func(int64) error {
+		t.Error()
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+// benchmarkSegments is 64 MiB of random data in buffers.SegmentSize slices,
+// as buffers.Buffers.ReadFrom returns it.
+func benchmarkSegments(t *testing.B) buffers.Buffers {
+	p := make([]byte, 64<<20)
+	rand.New(rand.NewSource(1)).Read(p)
+	var f buffers.Buffers
+	for ; len(p) > 0; p = p[buffers.SegmentSize:] {
+		f = f.Append(p[:buffers.SegmentSize])
+	}
+	t.SetBytes(int64(f.N))
+	t.ReportAllocs()
+	t.ResetTimer()
+	return f
+}
+
+func BenchmarkScan_Split(t *testing.B) {
+	f := benchmarkSegments(t)
+	s := &Split{Max: 1 << 20}
+	for n := 0; n < t.N; n++ {
+		for g := f; g.N > 0; {
+			_, g = s.Split(g)
+		}
+	}
+}
+
+func BenchmarkScan_Gear(t *testing.B) {
+	f := benchmarkSegments(t)
+	s := &Gear{}
+	var cuts []int
+	for n := 0; n < t.N; n++ {
+		cuts = s.Scan(f, cuts[:0])
+	}
+}
+
+func BenchmarkScan_Rolling(t *testing.B) {
+	f := benchmarkSegments(t)
+	s := &Split{Max: 1 << 20}
+	var cuts []int
+	for n := 0; n < t.N; n++ {
+		cuts = s.Scan(f, cuts[:0])
+	}
+}
@@ -2,6 +2,10 @@ package split
 
 import "github.com/daniel-fanjul-alcuten/floc/buffers"
 
+// DefaultMax is the default Max of a Split. It bounds the data that a Chunker
+// buffers for each chunk.
+const DefaultMax = 1 << 22
+
 // Split is the struct with the configuration and scratch space for the the
 // rolling hash implemented in the Split function.
 type Split struct {
@@ -16,7 +20,8 @@ type Split struct {
 	Mask uint32
 
 	// Cond is the value that the current value of the rolling hash must have
-	// after applying the Mask for the Split to trigger.
+	// after applying the Mask for the Split to trigger. It must have no bits
+	// outside the Mask.
 	Cond uint32
 
 	// Window is the size of the slice Ring.
@@ -32,7 +37,7 @@ type Split struct {
 // the Ring.
 func (h *Split) Reset() {
 	if h.Max == 0 {
-		h.Max = 1<<31 - 1
+		h.Max = DefaultMax
 	}
 	if h.Mask == 0 {
 		h.Mask = 1<<12 - 1
@@ -55,36 +60,29 @@ func (h *Split) Reset() {
 // Split splits the Buffers f into the Buffers g and r such that the
 // concatenation of g and r is equal to f, g.N >= h.Min, g.N <= h.Max, and the
 // rolling hash of g masked by h.Mask is equal to h.Cond or else g.N == h.Max.
-// h.Reset() is invoked before anything else.
+// The slices of f are shared with g and r like Buffers.SplitAt does. h.Reset()
+// is invoked before anything else.
 func (h *Split) Split(f buffers.Buffers) (g buffers.Buffers, r buffers.Buffers) {
 	h.Reset()
 	l, acc, min, max := 0, uint32(0), h.Min, h.Max
-	for i, p := range f.S {
+	for _, p := range f.S {
 		for j := 0; j < len(p); {
 			for ; j+3 < len(p) && l+3 < len(h.Ring); j += 4 {
 				acc += uint32(p[j+0]) - uint32(h.Ring[l+0])
 				if (j+1 >= min && acc&h.Mask == h.Cond) || j+1 >= max {
-					g = g.Append(p[:j+1])
-					r = r.Append(p[j+1:]).Append(f.S[i+1:]...)
-					return
+					return f.SplitAt(g.N + j + 1)
 				}
 				acc += uint32(p[j+1]) - uint32(h.Ring[l+1])
 				if (j+2 >= min && acc&h.Mask == h.Cond) || j+2 >= max {
-					g = g.Append(p[:j+2])
-					r = r.Append(p[j+2:]).Append(f.S[i+1:]...)
-					return
+					return f.SplitAt(g.N + j + 2)
 				}
 				acc += uint32(p[j+2]) - uint32(h.Ring[l+2])
 				if (j+3 >= min && acc&h.Mask == h.Cond) || j+3 >= max {
-					g = g.Append(p[:j+3])
-					r = r.Append(p[j+3:]).Append(f.S[i+1:]...)
-					return
+					return f.SplitAt(g.N + j + 3)
 				}
 				acc += uint32(p[j+3]) - uint32(h.Ring[l+3])
 				if (j+4 >= min && acc&h.Mask == h.Cond) || j+4 >= max {
-					g = g.Append(p[:j+4])
-					r = r.Append(p[j+4:]).Append(f.S[i+1:]...)
-					return
+					return f.SplitAt(g.N + j + 4)
 				}
 				h.Ring[l+0] = p[j+0]
 				h.Ring[l+1] = p[j+1]
@@ -95,9 +93,7 @@ func (h *Split) Split(f buffers.Buffers) (g buffers.Buffers, r buffers.Buffers)
 			if j < len(p) {
 				acc += uint32(p[j]) - uint32(h.Ring[l])
 				if (j+1 >= min && acc&h.Mask == h.Cond) || j+1 >= max {
-					g = g.Append(p[:j+1])
-					r = r.Append(p[j+1:]).Append(f.S[i+1:]...)
-					return
+					return f.SplitAt(g.N + j + 1)
 				}
 				h.Ring[l], l = p[j], (l+1)%len(h.Ring)
 				j++
@@ -93,6 +93,13 @@ func TestSplit_Max(t *testing.T) {
 	} else if n := r.N; n != 2 {
 		t.Error(n)
 	}
+	s = Split{}
+	g, r = s.Split(buffers.Buffers{}.Append(make([]byte, DefaultMax+1)))
+	if n := g.N; n != DefaultMax {
+		t.Error(n)
+	} else if n := r.N; n != 1 {
+		t.Error(n)
+	}
 }
 
 var benchmarkSplitBuffer []byte
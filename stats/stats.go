@@ -0,0 +1,307 @@
+// Package stats keeps the counters, gauges and histograms of a running Server
+// and exposes them in the Prometheus text format.
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Names of the metrics kept by the Servers and their backends.
+const (
+	Connections        = "floc_connections"
+	ConnectionsTotal   = "floc_connections_total"
+	Requests           = "floc_requests_total"
+	RequestErrors      = "floc_request_errors_total"
+	RequestSeconds     = "floc_request_duration_seconds"
+	ReceivedBytes      = "floc_received_bytes_total"
+	SentBytes          = "floc_sent_bytes_total"
+	ChunksStored       = "floc_chunks_stored_total"
+	ChunksDeduplicated = "floc_chunks_deduplicated_total"
+	StoredBytes        = "floc_stored_bytes_total"
+)
+
+// SecondsBounds are the default upper bounds of the buckets of the histograms
+// of durations.
+var SecondsBounds = []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 60}
+
+// Counter is a value that only increases.
+type Counter struct {
+	v int64
+}
+
+// Add adds n to the Counter.
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.v, n)
+}
+
+// Value returns the current value.
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.v)
+}
+
+// Gauge is a value that increases and decreases.
+type Gauge struct {
+	v int64
+}
+
+// Add adds n to the Gauge.
+func (g *Gauge) Add(n int64) {
+	atomic.AddInt64(&g.v, n)
+}
+
+// Set sets the Gauge to n.
+func (g *Gauge) Set(n int64) {
+	atomic.StoreInt64(&g.v, n)
+}
+
+// Value returns the current value.
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.v)
+}
+
+// Histogram counts observations in buckets with ascending upper Bounds. The
+// last bucket has no upper bound.
+type Histogram struct {
+	Bounds []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Observe adds v to the first bucket whose upper bound is not lower than v.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.mu.Lock()
+	if h.counts == nil {
+		h.counts = make([]uint64, len(h.Bounds)+1)
+	}
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// HistogramValue is the state of a Histogram. Counts are not cumulative and
+// have one more element than Bounds.
+type HistogramValue struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// Value returns the current state.
+func (h *Histogram) Value() HistogramValue {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v := HistogramValue{h.Bounds, make([]uint64, len(h.Bounds)+1), h.sum, h.count}
+	copy(v.Counts, h.counts)
+	return v
+}
+
+// Snapshot is the state of all metrics of a Stats by their keys.
+type Snapshot struct {
+	Counters   map[string]int64          `json:"counters"`
+	Gauges     map[string]int64          `json:"gauges"`
+	Histograms map[string]HistogramValue `json:"histograms"`
+}
+
+// Stats is a set of metrics identified by their keys. A key is a name with
+// optional labels, like floc_requests_total{method="Server.Stats"}. The zero
+// value is ready to use.
+type Stats struct {
+	mu         sync.Mutex
+	counters   map[string]*Counter
+	gauges     map[string]*Gauge
+	histograms map[string]*Histogram
+}
+
+// Key returns the key of the metric with the name and the labels, given as
+// pairs of label names and values.
+func Key(name string, labels ...string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", labels[i], labels[i+1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counter returns the Counter with the key, creating it if it does not exist.
+func (s *Stats) Counter(key string) *Counter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]*Counter)
+	}
+	c := s.counters[key]
+	if c == nil {
+		c = &Counter{}
+		s.counters[key] = c
+	}
+	return c
+}
+
+// Gauge returns the Gauge with the key, creating it if it does not exist.
+func (s *Stats) Gauge(key string) *Gauge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.gauges == nil {
+		s.gauges = make(map[string]*Gauge)
+	}
+	g := s.gauges[key]
+	if g == nil {
+		g = &Gauge{}
+		s.gauges[key] = g
+	}
+	return g
+}
+
+// Histogram returns the Histogram with the key, creating it with the bounds
+// if it does not exist.
+func (s *Stats) Histogram(key string, bounds []float64) *Histogram {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.histograms == nil {
+		s.histograms = make(map[string]*Histogram)
+	}
+	h := s.histograms[key]
+	if h == nil {
+		h = &Histogram{Bounds: bounds}
+		s.histograms[key] = h
+	}
+	return h
+}
+
+// Snapshot returns the current state of all metrics.
+func (s *Stats) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := Snapshot{
+		make(map[string]int64, len(s.counters)),
+		make(map[string]int64, len(s.gauges)),
+		make(map[string]HistogramValue, len(s.histograms)),
+	}
+	for k, c := range s.counters {
+		n.Counters[k] = c.Value()
+	}
+	for k, g := range s.gauges {
+		n.Gauges[k] = g.Value()
+	}
+	for k, h := range s.histograms {
+		n.Histograms[k] = h.Value()
+	}
+	return n
+}
+
+func splitKey(key string) (name, labels string) {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		return key[:i], key[i+1 : len(key)-1]
+	}
+	return key, ""
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ni, _ := splitKey(keys[i])
+		nj, _ := splitKey(keys[j])
+		if ni != nj {
+			return ni < nj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprint(f)
+}
+
+// WriteText writes all metrics to w in the Prometheus text format, sorted by
+// name and key.
+func (s *Stats) WriteText(w io.Writer) error {
+	n := s.Snapshot()
+	keys := make(map[string]struct{})
+	types := make(map[string]string)
+	for k := range n.Counters {
+		keys[k] = struct{}{}
+		name, _ := splitKey(k)
+		types[name] = "counter"
+	}
+	for k := range n.Gauges {
+		keys[k] = struct{}{}
+		name, _ := splitKey(k)
+		types[name] = "gauge"
+	}
+	for k := range n.Histograms {
+		keys[k] = struct{}{}
+		name, _ := splitKey(k)
+		types[name] = "histogram"
+	}
+	b := bufio.NewWriter(w)
+	last := ""
+	for _, k := range sortedKeys(keys) {
+		name, labels := splitKey(k)
+		if name != last {
+			fmt.Fprintf(b, "# TYPE %s %s\n", name, types[name])
+			last = name
+		}
+		if v, ok := n.Counters[k]; ok {
+			fmt.Fprintf(b, "%s %d\n", k, v)
+		} else if v, ok := n.Gauges[k]; ok {
+			fmt.Fprintf(b, "%s %d\n", k, v)
+		} else if v, ok := n.Histograms[k]; ok {
+			prefix := ""
+			if labels != "" {
+				prefix = labels + ","
+			}
+			acc := uint64(0)
+			for i, c := range v.Counts {
+				acc += c
+				le := math.Inf(1)
+				if i < len(v.Bounds) {
+					le = v.Bounds[i]
+				}
+				fmt.Fprintf(b, "%s_bucket{%sle=%q} %d\n", name, prefix, formatFloat(le), acc)
+			}
+			suffix := ""
+			if labels != "" {
+				suffix = "{" + labels + "}"
+			}
+			fmt.Fprintf(b, "%s_sum%s %s\n", name, suffix, formatFloat(v.Sum))
+			fmt.Fprintf(b, "%s_count%s %d\n", name, suffix, v.Count)
+		}
+	}
+	return b.Flush()
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format.
+func (s *Stats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.WriteText(w)
+}
@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	if s := Key("a"); s != "a" {
+		t.Error(s)
+	}
+	if s := Key("a", "b", "c"); s != `a{b="c"}` {
+		t.Error(s)
+	}
+	if s := Key("a", "b", "c", "d", `"e"`); s != `a{b="c",d="\"e\""}` {
+		t.Error(s)
+	}
+}
+
+func TestStats_Counter(t *testing.T) {
+	s := Stats{}
+	s.Counter("a").Add(1)
+	s.Counter("a").Add(2)
+	if v := s.Counter("a").Value(); v != 3 {
+		t.Error(v)
+	}
+	if v := s.Snapshot().Counters["a"]; v != 3 {
+		t.Error(v)
+	}
+}
+
+func TestStats_Gauge(t *testing.T) {
+	s := Stats{}
+	s.Gauge("a").Add(2)
+	s.Gauge("a").Add(-1)
+	if v := s.Gauge("a").Value(); v != 1 {
+		t.Error(v)
+	}
+	s.Gauge("a").Set(5)
+	if v := s.Snapshot().Gauges["a"]; v != 5 {
+		t.Error(v)
+	}
+}
+
+func TestStats_Histogram(t *testing.T) {
+	s := Stats{}
+	h := s.Histogram("a", []float64{1, 2})
+	h.Observe(0.5)
+	h.Observe(1)
+	h.Observe(3)
+	v := s.Snapshot().Histograms["a"]
+	if len(v.Counts) != 3 || v.Counts[0] != 2 || v.Counts[1] != 0 || v.Counts[2] != 1 {
+		t.Error(v.Counts)
+	}
+	if v.Sum != 4.5 {
+		t.Error(v.Sum)
+	}
+	if v.Count != 3 {
+		t.Error(v.Count)
+	}
+}
+
+func TestStats_WriteText(t *testing.T) {
+	s := Stats{}
+	s.Counter("b_total").Add(1)
+	s.Counter(Key("b", "m", "x")).Add(2)
+	s.Counter("b").Add(3)
+	s.Gauge("a").Set(4)
+	h := s.Histogram(Key("c", "m", "x"), []float64{1})
+	h.Observe(0.5)
+	h.Observe(2)
+	buf := bytes.Buffer{}
+	if err := s.WriteText(&buf); err != nil {
+		t.Error(err)
+	}
+	if s := buf.String(); s != `# TYPE a gauge
+a 4
+# TYPE b counter
+b 3
+b{m="x"} 2
+# TYPE b_total counter
+b_total 1
+# TYPE c histogram
+c_bucket{m="x",le="1"} 1
+c_bucket{m="x",le="+Inf"} 2
+c_sum{m="x"} 2.5
+c_count{m="x"} 2
+` {
+		t.Error(s)
+	}
+}
+
+func TestStats_ServeHTTP(t *testing.T) {
+	s := &Stats{}
+	s.Counter("a").Add(1)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if s := w.Body.String(); s != "# TYPE a counter\na 1\n" {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,246 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+)
+
+// The names of the JSON RPC methods of a Service and their params.
+const (
+	// CreateMethod params: Metadata.
+	CreateMethod = "Vault.Create"
+
+	// ListMethod has no params.
+	ListMethod = "Vault.List"
+
+	// GetMethod params: name.
+	GetMethod = "Vault.Get"
+
+	// DeleteMethod params: name.
+	DeleteMethod = "Vault.Delete"
+
+	// KeyringMethod params: name, Keyring or null.
+	KeyringMethod = "Vault.SetKeyring"
+
+	// BeginMethod params: vault.
+	BeginMethod = "Archive.Begin"
+
+	// AppendMethod params: vault, time, []Entry.
+	AppendMethod = "Archive.Append"
+
+	// CommitMethod params: vault, time.
+	CommitMethod = "Archive.Commit"
+
+	// AbortMethod params: vault, time.
+	AbortMethod = "Archive.Abort"
+
+	// ArchivesMethod params: vault.
+	ArchivesMethod = "Archive.List"
+
+	// ArchiveMethod params: vault, time.
+	ArchiveMethod = "Archive.Get"
+)
+
+// decode decodes the params into the pointers ps.
+func decode(params []interface{}, ps ...interface{}) error {
+	if err := jrpc.DecodeParams(params, ps...); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return nil
+}
+
+// Methods returns the JSON RPC methods of s, to be added to
+// server.Server.Methods.
+func Methods(s Service) map[string]jrpc.Method {
+	return map[string]jrpc.Method{
+		CreateMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var m Metadata
+			if err := decode(params, &m); err != nil {
+				return nil, err
+			}
+			return s.CreateVault(ctx, m)
+		},
+		ListMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			if err := decode(params); err != nil {
+				return nil, err
+			}
+			return s.ListVaults(ctx)
+		},
+		GetMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			if err := decode(params, &name); err != nil {
+				return nil, err
+			}
+			return s.GetVault(ctx, name)
+		},
+		DeleteMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			if err := decode(params, &name); err != nil {
+				return nil, err
+			}
+			return nil, s.DeleteVault(ctx, name)
+		},
+		KeyringMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			var k *crypt.Keyring
+			if err := decode(params, &name, &k); err != nil {
+				return nil, err
+			}
+			return s.SetKeyring(ctx, name, k)
+		},
+		BeginMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			if err := decode(params, &name); err != nil {
+				return nil, err
+			}
+			return s.BeginArchive(ctx, name)
+		},
+		AppendMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			var t time.Time
+			var entries []Entry
+			if err := decode(params, &name, &t, &entries); err != nil {
+				return nil, err
+			}
+			return nil, s.AppendArchive(ctx, name, t, entries)
+		},
+		CommitMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			var t time.Time
+			if err := decode(params, &name, &t); err != nil {
+				return nil, err
+			}
+			return s.CommitArchive(ctx, name, t)
+		},
+		AbortMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			var t time.Time
+			if err := decode(params, &name, &t); err != nil {
+				return nil, err
+			}
+			return s.AbortArchive(ctx, name, t)
+		},
+		ArchivesMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			if err := decode(params, &name); err != nil {
+				return nil, err
+			}
+			return s.ListArchives(ctx, name)
+		},
+		ArchiveMethod: func(ctx context.Context, params []interface{}) (interface{}, error) {
+			var name string
+			var t time.Time
+			if err := decode(params, &name, &t); err != nil {
+				return nil, err
+			}
+			return s.GetArchive(ctx, name, t)
+		},
+	}
+}
+
+// errs are the errors that Client restores from the messages of the
+// Responses.
+var errs = []error{ErrNotFound, ErrExists, ErrState, ErrInvalid}
+
+// Client implements Service by calling the methods of Methods through Conn.
+// The errors of the Service that wrap the errors of this package are returned
+// as errors that wrap them too.
+type Client struct {
+	Conn *jrpc.Conn
+}
+
+// Call calls the method with the params through c.Conn and decodes its
+// result into the value pointed to by result, unless it is nil. The errors of
+// this package are restored from the Response.
+func (c *Client) Call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	r, err := c.Conn.Call(ctx, method, params...)
+	if err != nil {
+		var e *jrpc.Error
+		if errors.As(err, &e) {
+			msg := e.Error()
+			for _, target := range errs {
+				if msg == target.Error() {
+					return target
+				}
+				if strings.HasPrefix(msg, target.Error()+":") {
+					return fmt.Errorf("%w%s", target, strings.TrimPrefix(msg, target.Error()))
+				}
+			}
+		}
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return jrpc.Convert(r, result)
+}
+
+// CreateVault implements Service.
+func (c *Client) CreateVault(ctx context.Context, m Metadata) (v Vault, err error) {
+	err = c.Call(ctx, &v, CreateMethod, m)
+	return
+}
+
+// ListVaults implements Service.
+func (c *Client) ListVaults(ctx context.Context) (vs []Vault, err error) {
+	err = c.Call(ctx, &vs, ListMethod)
+	return
+}
+
+// GetVault implements Service.
+func (c *Client) GetVault(ctx context.Context, name string) (v Vault, err error) {
+	err = c.Call(ctx, &v, GetMethod, name)
+	return
+}
+
+// DeleteVault implements Service.
+func (c *Client) DeleteVault(ctx context.Context, name string) error {
+	return c.Call(ctx, nil, DeleteMethod, name)
+}
+
+// SetKeyring implements Service.
+func (c *Client) SetKeyring(ctx context.Context, name string, k *crypt.Keyring) (v Vault, err error) {
+	err = c.Call(ctx, &v, KeyringMethod, name, k)
+	return
+}
+
+// BeginArchive implements Service.
+func (c *Client) BeginArchive(ctx context.Context, vault string) (a Archive, err error) {
+	err = c.Call(ctx, &a, BeginMethod, vault)
+	return
+}
+
+// AppendArchive implements Service.
+func (c *Client) AppendArchive(ctx context.Context, vault string, t time.Time, entries []Entry) error {
+	return c.Call(ctx, nil, AppendMethod, vault, t, entries)
+}
+
+// CommitArchive implements Service.
+func (c *Client) CommitArchive(ctx context.Context, vault string, t time.Time) (a Archive, err error) {
+	err = c.Call(ctx, &a, CommitMethod, vault, t)
+	return
+}
+
+// AbortArchive implements Service.
+func (c *Client) AbortArchive(ctx context.Context, vault string, t time.Time) (a Archive, err error) {
+	err = c.Call(ctx, &a, AbortMethod, vault, t)
+	return
+}
+
+// ListArchives implements Service.
+func (c *Client) ListArchives(ctx context.Context, vault string) (as []Archive, err error) {
+	err = c.Call(ctx, &as, ArchivesMethod, vault)
+	return
+}
+
+// GetArchive implements Service.
+func (c *Client) GetArchive(ctx context.Context, vault string, t time.Time) (a Archive, err error) {
+	err = c.Call(ctx, &a, ArchiveMethod, vault, t)
+	return
+}
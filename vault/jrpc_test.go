@@ -0,0 +1,29 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	var name string
+	var tm time.Time
+	if err := decode([]interface{}{"v", "2024-01-02T03:04:05.000000006Z"}, &name, &tm); err != nil {
+		t.Error(err)
+	}
+	if name != "v" || tm.Nanosecond() != 6 {
+		t.Error(name, tm)
+	}
+	if err := decode([]interface{}{"v"}, &name, &tm); !errors.Is(err, ErrInvalid) {
+		t.Error(err)
+	}
+	if err := decode([]interface{}{"v"}, &name); err != nil || name != "v" {
+		t.Error(name, err)
+	}
+	if err := decode([]interface{}{1.0}, &name); !errors.Is(err, ErrInvalid) {
+		t.Error(err)
+	} else if s := err.Error(); s != "vault: invalid argument: jrpc: invalid params: param 0: json: cannot unmarshal number into Go value of type string" {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,96 @@
+// Package vault defines the Vaults, which group the Archives of the backups
+// made with the same configuration.
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+)
+
+// ErrMismatch is returned by Metadata.Check when a client is not configured
+// like the Vault, which would silently prevent the deduplication.
+var ErrMismatch = errors.New("vault: configuration mismatch")
+
+// Metadata is the configuration of a Vault that is fixed when it is created
+// and that all clients must share.
+type Metadata struct {
+	Name string `json:"name"`
+
+	// Chunker is the normalized configuration of the Splitter.
+	Chunker split.Config `json:"chunker"`
+
+	// Fingerprint is the split.Config.Fingerprint() of Chunker.
+	Fingerprint string `json:"fingerprint"`
+
+	// ChunkID is the Algorithm of the chunk.IDs.
+	ChunkID chunk.Algorithm `json:"chunkId"`
+
+	// KeyCheck is the chunk.Hasher.Check() of the key of a keyed ChunkID.
+	KeyCheck []byte `json:"keyCheck,omitempty"`
+
+	// Keyring, if it is not nil, is the encrypted master key of the Vault,
+	// which is kept by the Servers for the clients. It is the only field
+	// that changes, with Service.SetKeyring.
+	Keyring *crypt.Keyring `json:"keyring,omitempty"`
+}
+
+// NewMetadata returns the Metadata of a new Vault with the name, the
+// configuration of its Splitter and the Hasher of its chunk.IDs. The key of
+// the Hasher is not kept.
+func NewMetadata(name string, c split.Config, h chunk.Hasher) (m Metadata, err error) {
+	m.Name = name
+	if m.Chunker, err = c.Normalize(); err != nil {
+		return
+	}
+	if m.Fingerprint, err = c.Fingerprint(); err != nil {
+		return
+	}
+	m.ChunkID = h.Algorithm
+	m.KeyCheck, err = h.Check()
+	return
+}
+
+// Check returns an error that wraps ErrMismatch if c or h do not match the
+// configuration of the Vault.
+func (m Metadata) Check(c split.Config, h chunk.Hasher) error {
+	f, err := c.Fingerprint()
+	if err != nil {
+		return err
+	}
+	if f != m.Fingerprint {
+		return fmt.Errorf("%w: chunker %v is not %v of vault %q", ErrMismatch, f, m.Fingerprint, m.Name)
+	}
+	if h.Algorithm != m.ChunkID {
+		return fmt.Errorf("%w: chunk id %v is not %v of vault %q", ErrMismatch, h.Algorithm, m.ChunkID, m.Name)
+	}
+	check, err := h.Check()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(check, m.KeyCheck) {
+		return fmt.Errorf("%w: chunk id key of vault %q", ErrMismatch, m.Name)
+	}
+	return nil
+}
+
+// Validate returns an error that wraps ErrInvalid if m has no Name or it is
+// not a valid file name, has an unknown ChunkID or its Fingerprint does not
+// match its Chunker.
+func (m Metadata) Validate() error {
+	if m.Name == "" || m.Name == "." || m.Name == ".." || strings.ContainsAny(m.Name, "/\\\x00") {
+		return fmt.Errorf("%w: vault name %q", ErrInvalid, m.Name)
+	}
+	if !m.ChunkID.Valid() {
+		return fmt.Errorf("%w: %v", ErrInvalid, chunk.ErrAlgorithm)
+	}
+	if f, err := m.Chunker.Fingerprint(); err != nil || f != m.Fingerprint {
+		return fmt.Errorf("%w: chunker fingerprint", ErrInvalid)
+	}
+	return nil
+}
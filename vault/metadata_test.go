@@ -0,0 +1,79 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+)
+
+func TestMetadata_Check(t *testing.T) {
+	c := split.Config{Algorithm: split.FastCDC}
+	h := chunk.Hasher{Algorithm: chunk.HMACSHA256, Key: []byte("key")}
+	m, err := NewMetadata("v1", c, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Chunker.Avg != 1<<13 {
+		t.Error(m.Chunker)
+	}
+	m.Keyring = &crypt.Keyring{Vault: "v1", Slots: []crypt.Slot{{Name: "s1", KDF: crypt.Raw, Key: []byte("k")}}}
+	p, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m2 Metadata
+	if err := json.Unmarshal(p, &m2); err != nil {
+		t.Fatal(err)
+	}
+	if k := m2.Keyring; k == nil || len(k.Slots) != 1 || string(k.Slots[0].Key) != "k" {
+		t.Error(k)
+	}
+	if err := m2.Check(split.Config{Algorithm: split.FastCDC, Avg: 1 << 13}, h); err != nil {
+		t.Error(err)
+	}
+	if err := m2.Check(split.Config{Algorithm: split.FastCDC, Avg: 1 << 12}, h); !errors.Is(err, ErrMismatch) {
+		t.Error(err)
+	}
+	if err := m2.Check(c, chunk.Hasher{Algorithm: chunk.SHA256}); !errors.Is(err, ErrMismatch) {
+		t.Error(err)
+	}
+	if err := m2.Check(c, chunk.Hasher{Algorithm: chunk.HMACSHA256, Key: []byte("other")}); !errors.Is(err, ErrMismatch) {
+		t.Error(err)
+	} else if s := err.Error(); s != `vault: configuration mismatch: chunk id key of vault "v1"` {
+		t.Error(s)
+	}
+	if _, err := NewMetadata("v2", split.Config{}, h); err != split.ErrAlgorithm {
+		t.Error(err)
+	}
+	if _, err := NewMetadata("v2", c, chunk.Hasher{Algorithm: chunk.HMACSHA256}); err != chunk.ErrKey {
+		t.Error(err)
+	}
+}
+
+func TestMetadata_Validate(t *testing.T) {
+	m, err := NewMetadata("v1", split.Config{Algorithm: split.Rolling}, chunk.Hasher{Algorithm: chunk.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Error(err)
+	}
+	for i, f := range []func(*Metadata){
+		func(m *Metadata) { m.Name = "" },              // 0
+		func(m *Metadata) { m.ChunkID = 0 },            // 1
+		func(m *Metadata) { m.Chunker.Window = 64 },    // 2
+		func(m *Metadata) { m.Chunker.Algorithm = "" }, // 3
+		func(m *Metadata) { m.Name = "a/b" },           // 4
+		func(m *Metadata) { m.Name = ".." },            // 5
+	} {
+		m2 := m
+		f(&m2)
+		if err := m2.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Error(i, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+)
+
+var (
+	// ErrNotFound is returned when a Vault or an Archive does not exist.
+	ErrNotFound = errors.New("vault: not found")
+
+	// ErrExists is returned when a Vault is created with a name in use.
+	ErrExists = errors.New("vault: already exists")
+
+	// ErrState is returned when an Archive that is not Open is modified.
+	ErrState = errors.New("vault: archive is not open")
+
+	// ErrInvalid is returned for invalid names, params or fields.
+	ErrInvalid = errors.New("vault: invalid argument")
+)
+
+// Vault is a group of Archives made with the same configuration. It is
+// identified by its name.
+type Vault struct {
+	Metadata
+	Created time.Time `json:"created"`
+}
+
+// State is the state of an Archive.
+type State string
+
+// The States of the Archives.
+const (
+	// Open is the State of an Archive that is being uploaded.
+	Open State = "open"
+
+	// Complete is the State of an Archive that was fully uploaded.
+	Complete State = "complete"
+
+	// Incomplete is the State of an Archive whose upload was interrupted.
+	Incomplete State = "incomplete"
+)
+
+// Archive is the full view of a single backup. It belongs to one Vault and is
+// identified by the name of the Vault and its Time.
+type Archive struct {
+	Vault string    `json:"vault"`
+	Time  time.Time `json:"time"`
+	State State     `json:"state"`
+
+	// Finished is the time of the Commit or the Abort.
+	Finished time.Time `json:"finished,omitzero"`
+
+	// Entries are the files of the Archive in the order they were appended.
+	// They are omitted by Service.ListArchives.
+	Entries []Entry `json:"entries,omitempty"`
+}
+
+// Entry is a file of an Archive: its document of a Catalog, extended with the
+// references to the chunks of its contents.
+type Entry struct {
+
+	// Path is the path of the file. It may be empty when Meta is encrypted.
+	Path string `json:"path,omitempty"`
+
+	// Meta is the document of the Catalog, which may be encrypted by the
+	// client as a JSON string.
+	Meta json.RawMessage `json:"meta,omitempty"`
+
+	// Chunks are the contents of the file in order.
+	Chunks []ChunkRef `json:"chunks,omitempty"`
+}
+
+// ChunkRef refers to the chunk with the ID at the Offset of a file.
+type ChunkRef struct {
+	ID     chunk.ID `json:"id"`
+	Offset int64    `json:"offset"`
+	Length int      `json:"length"`
+}
+
+// Service is the API of the Vaults and the Archives that every backend server
+// implements. The Times of the Archives of a Vault are assigned by BeginArchive
+// and are strictly increasing.
+type Service interface {
+
+	// CreateVault creates a Vault with the Metadata, or returns ErrExists.
+	// Its Keyring, if any, must be of the Vault.
+	CreateVault(ctx context.Context, m Metadata) (Vault, error)
+
+	// ListVaults returns all Vaults sorted by name.
+	ListVaults(ctx context.Context) ([]Vault, error)
+
+	// GetVault returns the Vault with the name.
+	GetVault(ctx context.Context, name string) (Vault, error)
+
+	// SetKeyring replaces the Keyring of the Vault with the name, or removes
+	// it if k is nil, and returns the Vault. The Keyring must be of the Vault.
+	// The rest of the Metadata never changes.
+	SetKeyring(ctx context.Context, name string, k *crypt.Keyring) (Vault, error)
+
+	// DeleteVault deletes the Vault with the name and all its Archives.
+	DeleteVault(ctx context.Context, name string) error
+
+	// BeginArchive creates an Open Archive in the Vault with the name.
+	BeginArchive(ctx context.Context, vault string) (Archive, error)
+
+	// AppendArchive appends the entries to an Open Archive.
+	AppendArchive(ctx context.Context, vault string, t time.Time, entries []Entry) error
+
+	// CommitArchive makes an Open Archive Complete.
+	CommitArchive(ctx context.Context, vault string, t time.Time) (Archive, error)
+
+	// AbortArchive makes an Open Archive Incomplete.
+	AbortArchive(ctx context.Context, vault string, t time.Time) (Archive, error)
+
+	// ListArchives returns the Archives of the Vault sorted by Time and
+	// without Entries.
+	ListArchives(ctx context.Context, vault string) ([]Archive, error)
+
+	// GetArchive returns the Archive with its Entries.
+	GetArchive(ctx context.Context, vault string, t time.Time) (Archive, error)
+}
@@ -0,0 +1,27 @@
+package vault
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchive_JSON(t *testing.T) {
+	a := Archive{Vault: "v", Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), State: Open}
+	p, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(p); strings.Contains(s, "finished") {
+		t.Error(s)
+	}
+	a.State, a.Finished = Complete, a.Time.Add(time.Second)
+	if p, err = json.Marshal(a); err != nil {
+		t.Fatal(err)
+	}
+	var b Archive
+	if err := json.Unmarshal(p, &b); err != nil || !b.Finished.Equal(a.Finished) {
+		t.Error(string(p), err)
+	}
+}
@@ -0,0 +1,357 @@
+// Package vaulttest is the conformance test suite of the implementations of
+// vault.Service, which every backend runs from its own tests.
+package vaulttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/daniel-fanjul-alcuten/floc/chunk"
+	"github.com/daniel-fanjul-alcuten/floc/crypt"
+	"github.com/daniel-fanjul-alcuten/floc/jrpc"
+	"github.com/daniel-fanjul-alcuten/floc/split"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+// Test runs the suite against the empty Services returned by newService,
+// first directly and then through vault.Methods and a vault.Client.
+func Test(t *testing.T, newService func(t *testing.T) vault.Service) {
+	t.Run("Direct", func(t *testing.T) {
+		run(t, newService)
+	})
+	t.Run("JRPC", func(t *testing.T) {
+		run(t, func(t *testing.T) vault.Service {
+			return Pipe(t, newService(t))
+		})
+	})
+}
+
+// Pipe serves s through a net.Pipe until the end of the test and returns a
+// vault.Client of it.
+func Pipe(t *testing.T, s vault.Service) *vault.Client {
+	return &vault.Client{Conn: Serve(t, vault.Methods(s))}
+}
+
+// Serve serves the methods through a net.Pipe until the end of the test and
+// returns the jrpc.Conn of the other end.
+func Serve(t *testing.T, methods map[string]jrpc.Method) *jrpc.Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	c1, c2 := net.Pipe()
+	server := &jrpc.Conn{Ctx: ctx, Conn: c1, Methods: methods}
+	client := &jrpc.Conn{Ctx: ctx, Conn: c2}
+	done := make(chan struct{}, 2)
+	go func() {
+		server.Serve()
+		done <- struct{}{}
+	}()
+	go func() {
+		client.Serve()
+		done <- struct{}{}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		<-done
+	})
+	return client
+}
+
+// Metadata returns valid Metadata of a Vault with the name.
+func Metadata(t *testing.T, name string) vault.Metadata {
+	m, err := vault.NewMetadata(name, split.Config{Algorithm: split.FastCDC}, chunk.Hasher{Algorithm: chunk.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func run(t *testing.T, newService func(t *testing.T) vault.Service) {
+	for _, c := range []struct {
+		name string
+		f    func(*testing.T, vault.Service)
+	}{
+		{"Vaults", testVaults},
+		{"Archives", testArchives},
+		{"States", testStates},
+		{"Errors", testErrors},
+		{"DeleteVault", testDeleteVault},
+		{"Keyring", testKeyring},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.f(t, newService(t))
+		})
+	}
+}
+
+func names(vs []vault.Vault) (s []string) {
+	for _, v := range vs {
+		s = append(s, v.Name)
+	}
+	return
+}
+
+func testVaults(t *testing.T, s vault.Service) {
+	ctx := context.Background()
+	if vs, err := s.ListVaults(ctx); err != nil || len(vs) != 0 {
+		t.Fatal(vs, err)
+	}
+	before := time.Now().Add(-time.Second)
+	for _, name := range []string{"b", "c", "a"} {
+		v, err := s.CreateVault(ctx, Metadata(t, name))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if v.Name != name || v.Created.Before(before) {
+			t.Error(name, v)
+		}
+	}
+	if _, err := s.CreateVault(ctx, Metadata(t, "a")); !errors.Is(err, vault.ErrExists) {
+		t.Error(err)
+	}
+	vs, err := s.ListVaults(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fmt.Sprint(names(vs)); n != "[a b c]" {
+		t.Error(n)
+	}
+	m := Metadata(t, "b")
+	v, err := s.GetVault(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Fingerprint != m.Fingerprint || v.ChunkID != m.ChunkID || v.Chunker != m.Chunker {
+		t.Error(v)
+	}
+	if err := v.Check(split.Config{Algorithm: split.FastCDC}, chunk.Hasher{Algorithm: chunk.SHA256}); err != nil {
+		t.Error(err)
+	}
+	if err := s.DeleteVault(ctx, "b"); err != nil {
+		t.Error(err)
+	}
+	if _, err := s.GetVault(ctx, "b"); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if err := s.DeleteVault(ctx, "b"); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if vs, err := s.ListVaults(ctx); err != nil || fmt.Sprint(names(vs)) != "[a c]" {
+		t.Error(names(vs), err)
+	}
+}
+
+func testEntries(n int) []vault.Entry {
+	var entries []vault.Entry
+	for i := 0; i < n; i++ {
+		id := chunk.ID{Algorithm: chunk.SHA256}
+		id.Sum[0] = byte(i)
+		entries = append(entries, vault.Entry{
+			Path:   fmt.Sprintf("dir/file%d", i),
+			Meta:   json.RawMessage(fmt.Sprintf(`{"mode":%d}`, 0644+i)),
+			Chunks: []vault.ChunkRef{{ID: id, Offset: 0, Length: 10 + i}, {ID: id, Offset: int64(10 + i), Length: 1}},
+		})
+	}
+	return entries
+}
+
+func testArchives(t *testing.T, s vault.Service) {
+	ctx := context.Background()
+	if _, err := s.CreateVault(ctx, Metadata(t, "v")); err != nil {
+		t.Fatal(err)
+	}
+	if as, err := s.ListArchives(ctx, "v"); err != nil || len(as) != 0 {
+		t.Fatal(as, err)
+	}
+	var times []time.Time
+	for i := 0; i < 3; i++ {
+		a, err := s.BeginArchive(ctx, "v")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Vault != "v" || a.State != vault.Open || len(a.Entries) != 0 {
+			t.Error(i, a)
+		}
+		if len(times) > 0 && !a.Time.After(times[len(times)-1]) {
+			t.Error(i, a.Time, times)
+		}
+		times = append(times, a.Time)
+	}
+	entries := testEntries(5)
+	if err := s.AppendArchive(ctx, "v", times[1], entries[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendArchive(ctx, "v", times[1], entries[2:]); err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.CommitArchive(ctx, "v", times[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.State != vault.Complete || a.Finished.IsZero() || !a.Time.Equal(times[1]) {
+		t.Error(a)
+	}
+	a, err = s.GetArchive(ctx, "v", times[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1, _ := json.Marshal(a.Entries)
+	p2, _ := json.Marshal(entries)
+	if string(p1) != string(p2) {
+		t.Error(string(p1))
+	}
+	as, err := s.ListArchives(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 3 {
+		t.Fatal(as)
+	}
+	for i, a := range as {
+		if !a.Time.Equal(times[i]) || len(a.Entries) != 0 {
+			t.Error(i, a)
+		}
+	}
+	if as[0].State != vault.Open || as[1].State != vault.Complete {
+		t.Error(as)
+	}
+}
+
+func testStates(t *testing.T, s vault.Service) {
+	ctx := context.Background()
+	if _, err := s.CreateVault(ctx, Metadata(t, "v")); err != nil {
+		t.Fatal(err)
+	}
+	a1, err := s.BeginArchive(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := s.BeginArchive(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, err := s.AbortArchive(ctx, "v", a1.Time); err != nil || a.State != vault.Incomplete || a.Finished.IsZero() {
+		t.Error(a, err)
+	}
+	if _, err := s.CommitArchive(ctx, "v", a2.Time); err != nil {
+		t.Error(err)
+	}
+	for i, a := range []vault.Archive{a1, a2} {
+		if err := s.AppendArchive(ctx, "v", a.Time, testEntries(1)); !errors.Is(err, vault.ErrState) {
+			t.Error(i, err)
+		}
+		if _, err := s.CommitArchive(ctx, "v", a.Time); !errors.Is(err, vault.ErrState) {
+			t.Error(i, err)
+		}
+		if _, err := s.AbortArchive(ctx, "v", a.Time); !errors.Is(err, vault.ErrState) {
+			t.Error(i, err)
+		}
+	}
+	if a, err := s.GetArchive(ctx, "v", a1.Time); err != nil || a.State != vault.Incomplete {
+		t.Error(a, err)
+	}
+}
+
+func testErrors(t *testing.T, s vault.Service) {
+	ctx := context.Background()
+	m := Metadata(t, "")
+	if _, err := s.CreateVault(ctx, m); !errors.Is(err, vault.ErrInvalid) {
+		t.Error(err)
+	}
+	m = Metadata(t, "v")
+	m.Fingerprint = "0"
+	if _, err := s.CreateVault(ctx, m); !errors.Is(err, vault.ErrInvalid) {
+		t.Error(err)
+	}
+	m = Metadata(t, "v")
+	m.Keyring = &crypt.Keyring{Vault: "w"}
+	if _, err := s.CreateVault(ctx, m); !errors.Is(err, vault.ErrInvalid) {
+		t.Error(err)
+	}
+	if _, err := s.GetVault(ctx, "v"); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if _, err := s.BeginArchive(ctx, "v"); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if _, err := s.ListArchives(ctx, "v"); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if _, err := s.CreateVault(ctx, Metadata(t, "v")); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := s.GetArchive(ctx, "v", now); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if err := s.AppendArchive(ctx, "v", now, nil); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if _, err := s.CommitArchive(ctx, "v", now); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	if _, err := s.AbortArchive(ctx, "v", now); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+}
+
+func testDeleteVault(t *testing.T, s vault.Service) {
+	ctx := context.Background()
+	if _, err := s.CreateVault(ctx, Metadata(t, "v")); err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.BeginArchive(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVault(ctx, "v"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateVault(ctx, Metadata(t, "v")); err != nil {
+		t.Fatal(err)
+	}
+	if as, err := s.ListArchives(ctx, "v"); err != nil || len(as) != 0 {
+		t.Error(as, err)
+	}
+	if _, err := s.GetArchive(ctx, "v", a.Time); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+}
+
+func testKeyring(t *testing.T, s vault.Service) {
+	ctx := context.Background()
+	k := &crypt.Keyring{Vault: "v", Slots: []crypt.Slot{{Name: "s1", KDF: crypt.Raw, Key: []byte("k")}}}
+	if _, err := s.SetKeyring(ctx, "v", k); !errors.Is(err, vault.ErrNotFound) {
+		t.Error(err)
+	}
+	m := Metadata(t, "v")
+	if _, err := s.CreateVault(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.SetKeyring(ctx, "v", k); err != nil || v.Keyring == nil || v.Fingerprint != m.Fingerprint {
+		t.Error(v, err)
+	}
+	v, err := s.GetVault(ctx, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := v.Keyring; k == nil || len(k.Slots) != 1 || string(k.Slots[0].Key) != "k" {
+		t.Error(k)
+	}
+	if v.Fingerprint != m.Fingerprint || v.ChunkID != m.ChunkID {
+		t.Error(v)
+	}
+	if _, err := s.SetKeyring(ctx, "v", &crypt.Keyring{Vault: "w"}); !errors.Is(err, vault.ErrInvalid) {
+		t.Error(err)
+	}
+	if _, err := s.SetKeyring(ctx, "v", nil); err != nil {
+		t.Error(err)
+	}
+	if v, err := s.GetVault(ctx, "v"); err != nil || v.Keyring != nil {
+		t.Error(v, err)
+	}
+}
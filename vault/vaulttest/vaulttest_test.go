@@ -0,0 +1,15 @@
+package vaulttest
+
+import (
+	"testing"
+
+	"github.com/daniel-fanjul-alcuten/floc/backend"
+	"github.com/daniel-fanjul-alcuten/floc/backend/mem"
+	"github.com/daniel-fanjul-alcuten/floc/vault"
+)
+
+func TestService(t *testing.T) {
+	Test(t, func(t *testing.T) vault.Service {
+		return &backend.Service{Backend: &mem.Backend{}}
+	})
+}